@@ -0,0 +1,14 @@
+package argparse
+
+type ArgOptional struct {
+	Arg     Parser
+	Default interface{}
+}
+
+func (arg ArgOptional) Empty() (interface{}, error) {
+	return arg.Default, nil
+}
+
+func (arg ArgOptional) Parse(str string) (interface{}, int, error) {
+	return arg.Arg.Parse(str)
+}
@@ -0,0 +1,26 @@
+package argparse_test
+
+import (
+	"testing"
+
+	ap "github.com/Roman2K/scat/argparse"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestArgOptional(t *testing.T) {
+	args := ap.Args{ap.ArgStr, ap.ArgOptional{ap.ArgInt, -1}}
+
+	res, _, err := args.Parse("a 2")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", 2}, res)
+
+	res, _, err = args.Parse("a")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", -1}, res)
+
+	_, _, err = args.Parse("a x")
+	assert.Error(t, err)
+
+	_, _, err = args.Parse("")
+	assert.Equal(t, ap.ErrTooFewArgs, err)
+}
@@ -1,14 +1,20 @@
 package argproc
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"strings"
+	"time"
 
 	"github.com/Roman2K/scat"
 	ap "github.com/Roman2K/scat/argparse"
+	"github.com/Roman2K/scat/index"
 	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/split"
 	"github.com/Roman2K/scat/stats"
 	"github.com/Roman2K/scat/stores"
 	"github.com/Roman2K/scat/stores/quota"
@@ -20,11 +26,77 @@ import (
 var chainBrackets = ap.Brackets{'{', '}'}
 
 func New(tmp *tmpdedup.Dir, stats *stats.Statsd) ap.Parser {
-	argProc := builder{tmp, stats}.argProc()
+	return NewHeader(tmp, stats, nil)
+}
+
+// Makes index procs write hdr, completed with split params.
+func NewHeader(tmp *tmpdedup.Dir, stats *stats.Statsd, hdr *index.Header) (
+	ap.Parser,
+) {
+	return parserFunc(func(str string) (res interface{}, n int, err error) {
+		b := builder{tmp, stats, hdr, &joinLink{}}
+		res, n, err = ap.ArgPiped{Arg: b.argProc(), Nest: chainBrackets}.
+			Parse(str)
+		if err != nil {
+			return
+		}
+		err = b.link.finish()
+		if err != nil {
+			return
+		}
+		res = newChain(res.([]interface{}))
+		return
+	})
+}
+
+type parserFunc func(string) (interface{}, int, error)
+
+func (fn parserFunc) Parse(str string) (interface{}, int, error) {
+	return fn(str)
+}
+
+func NewStores(tmp *tmpdedup.Dir) ap.Parser {
 	return ap.ArgFilter{
-		Parser: ap.ArgPiped{Arg: argProc, Nest: chainBrackets},
+		Parser: ap.ArgVariadic{newArgNamedStore(builder{tmp: tmp}.newArgStore())},
 		Filter: func(val interface{}) (interface{}, error) {
-			return newChain(val.([]interface{})), nil
+			ivals := val.([]interface{})
+			ss := make([]stores.NamedStore, len(ivals))
+			for i, v := range ivals {
+				ss[i] = v.(stores.NamedStore)
+			}
+			return ss, nil
+		},
+	}
+}
+
+type StripeSpec struct {
+	Config stripe.Config
+	Qman   *quota.Man
+}
+
+func NewStripeSpec(tmp *tmpdedup.Dir) ap.Parser {
+	b := builder{tmp: tmp}
+	argQuota := b.newArgQuota(newArgNamedStore(b.newArgStore()))
+	return newArgStripe(argQuota, func(min, excl int, iress []interface{}) (
+		interface{}, error,
+	) {
+		qman := quota.NewMan()
+		addQuotaRess(qman, iress)
+		cfg := stripe.Config{Min: min, Excl: excl}
+		return StripeSpec{Config: cfg, Qman: qman}, nil
+	})
+}
+
+func newArgNamedStore(argStore ap.Parser) ap.Parser {
+	return ap.ArgPair{
+		Left:  ap.ArgStr,
+		Right: argStore,
+		Run: func(iid, istore interface{}) (interface{}, error) {
+			named := stores.NamedStore{
+				IdVal: iid.(string),
+				Store: istore.(stores.Store),
+			}
+			return named, nil
 		},
 	}
 }
@@ -40,6 +112,73 @@ func newChain(args []interface{}) (chain procs.Chain) {
 type builder struct {
 	tmp   *tmpdedup.Dir
 	stats *stats.Statsd
+	hdr   *index.Header
+	link  *joinLink
+}
+
+// Hands the range of each uindex to the joins it feeds, and the window of a
+// bounded join back to its uindex for backpressure. A join is fed by the
+// closest uindex before it in the proc string, or else after it.
+type joinLink struct {
+	procs []interface{} // *uindexProc and *joinProc, in parse order
+}
+
+type uindexProc struct {
+	procs.Proc
+	rng *procs.ByteRange
+}
+
+// Built once linked, with the range of its uindex, nil if none.
+type joinProc struct {
+	procs.Proc
+	win   *procs.SortWindow
+	build func(*procs.ByteRange) procs.Proc
+}
+
+var errLinkWindow = errors.New("only one bounded join allowed per uindex")
+
+func (l *joinLink) add(p interface{}) {
+	l.procs = append(l.procs, p)
+}
+
+// Called once the whole proc string is parsed.
+func (l *joinLink) finish() error {
+	wins := make(map[*uindexProc]*procs.SortWindow)
+	for i, p := range l.procs {
+		j, ok := p.(*joinProc)
+		if !ok {
+			continue
+		}
+		var rng *procs.ByteRange
+		if u := l.uindexOf(i); u != nil {
+			rng = u.rng
+			if j.win != nil {
+				if _, ok := wins[u]; ok {
+					return errLinkWindow
+				}
+				wins[u] = j.win
+			}
+		}
+		j.Proc = j.build(rng)
+	}
+	for u, win := range wins {
+		u.Proc = procs.Chain{u.Proc, procs.NewSortWait(win)}
+	}
+	return nil
+}
+
+func (l *joinLink) uindexOf(i int) *uindexProc {
+	for k := i - 1; k >= 0; k-- {
+		if u, ok := l.procs[k].(*uindexProc); ok {
+			return u
+		}
+	}
+	for _, p := range l.procs[i+1:] {
+		if u, ok := p.(*uindexProc); ok {
+			return u
+		}
+	}
+	return nil
 }
 
 func (b builder) argProc() ap.Parser {
@@ -57,11 +196,7 @@ func (b builder) argProc() ap.Parser {
 		},
 	}
 
-	procFns := b.newArgProc(argProc, argDynProc, argStore)
-	for k, v := range argStore {
-		procFns[k] = newArgStoreProc(v, getProc)
-		procFns["u"+k] = newArgStoreProc(v, getUnproc)
-	}
+	procFns := b.newArgProcFns(argProc, argDynProc, argStore)
 	if b.stats != nil {
 		for k, v := range procFns {
 			procFns[k] = b.newArgStatsProc(v, k)
@@ -73,6 +208,18 @@ func (b builder) argProc() ap.Parser {
 	return argProc
 }
 
+func (b builder) newArgProcFns(argProc, argDynp ap.Parser, argStore ap.ArgFn) (
+	procFns ap.ArgFn,
+) {
+	procFns = b.newArgProc(argProc, argDynp, argStore)
+	for k, v := range argStore {
+		procFns[k] = newArgStoreProc(v, getProc)
+		procFns["u"+k] = newArgStoreProc(v, getUnproc)
+		procFns["rm"+k] = newArgStoreDeleteProc(v)
+	}
+	return
+}
+
 func newArgStoreProc(argStore ap.Parser, getProc getProcFn) ap.Parser {
 	return ap.ArgFilter{
 		Parser: argStore,
@@ -83,6 +230,19 @@ func newArgStoreProc(argStore ap.Parser, getProc getProcFn) ap.Parser {
 	}
 }
 
+func newArgStoreDeleteProc(argStore ap.Parser) ap.Parser {
+	return ap.ArgFilter{
+		Parser: argStore,
+		Filter: func(val interface{}) (interface{}, error) {
+			del, ok := val.(stores.Deleter)
+			if !ok {
+				return nil, stores.ErrNoDelete
+			}
+			return stores.NewDeleteProc(del), nil
+		},
+	}
+}
+
 func (b builder) newArgStatsProc(argProc ap.Parser, id interface{}) ap.Parser {
 	return ap.ArgFilter{
 		Parser: argProc,
@@ -94,6 +254,8 @@ func (b builder) newArgStatsProc(argProc ap.Parser, id interface{}) ap.Parser {
 }
 
 func (b builder) newArgProc(argProc, argDynp, argStore ap.Parser) ap.ArgFn {
+	argCopier := b.newArgCopier(argStore, getUnproc)
+
 	return ap.ArgFn{
 		"checksum": ap.ArgLambda{
 			Run: func([]interface{}) (interface{}, error) {
@@ -112,16 +274,38 @@ func (b builder) newArgProc(argProc, argDynp, argStore ap.Parser) ap.ArgFn {
 					path = args[0].(string)
 				)
 				w, err := openOut(path)
+				if b.hdr != nil {
+					return procs.NewHeaderIndexProc(w, b.hdr), err
+				}
 				return procs.NewIndexProc(w), err
 			},
 		},
 		"uindex": ap.ArgLambda{
-			Run: func([]interface{}) (interface{}, error) {
-				return procs.IndexUnproc, nil
+			Args: ap.Args{
+				ap.ArgOptional{ap.ArgBytes, uint64(0)},
+				ap.ArgOptional{ap.ArgBytes, uint64(0)},
+			},
+			Run: func(args []interface{}) (interface{}, error) {
+				var (
+					start = args[0].(uint64)
+					size  = args[1].(uint64)
+				)
+				rng := &procs.ByteRange{Start: int64(start)}
+				unproc := procs.NewIndexUnproc(rng)
+				if start != 0 || size != 0 {
+					if size != 0 {
+						rng.End = rng.Start + int64(size)
+					}
+					unproc = procs.NewIndexRangeUnproc(rng)
+				}
+				p := &uindexProc{unproc, rng}
+				b.link.add(p)
+				return p, nil
 			},
 		},
 		"split": ap.ArgLambda{
 			Run: func([]interface{}) (interface{}, error) {
+				b.setSplit(split.DefaultMin, split.DefaultMax)
 				return procs.Split, nil
 			},
 		},
@@ -132,9 +316,15 @@ func (b builder) newArgProc(argProc, argDynp, argStore ap.Parser) ap.ArgFn {
 					min = uintBytes(args[0])
 					max = uintBytes(args[1])
 				)
+				b.setSplit(min, max)
 				return procs.NewSplitSize(min, max), nil
 			},
 		},
+		"sparse": ap.ArgLambda{
+			Run: func([]interface{}) (interface{}, error) {
+				return procs.Sparse, nil
+			},
+		},
 		"backlog": ap.ArgLambda{
 			Args: ap.Args{ap.ArgInt, argProc},
 			Run: func(args []interface{}) (interface{}, error) {
@@ -156,19 +346,58 @@ func (b builder) newArgProc(argProc, argDynp, argStore ap.Parser) ap.ArgFn {
 			},
 		},
 		"multireader": ap.ArgLambda{
-			Args: ap.ArgVariadic{b.newArgCopier(argStore, getUnproc)},
+			Args: ap.ArgVariadic{ap.ArgOr{argMaybeInt{}, argCopier}},
 			Run: func(args []interface{}) (interface{}, error) {
-				copiers := make([]stores.Copier, len(args))
-				for i, icp := range args {
-					copiers[i] = icp.(stores.Copier)
+				pct, ok := 0, false
+				if len(args) > 0 {
+					pct, ok = args[0].(int)
+				}
+				if ok {
+					args = args[1:]
+					if pct < 1 || pct > 100 {
+						return nil, errors.New("percentile must be within 1-100")
+					}
 				}
-				return stores.NewMultiReader(copiers)
+				for _, arg := range args {
+					if _, ok := arg.(int); ok {
+						return nil, errors.New("percentile must come before stores")
+					}
+				}
+				return b.newMultiReader(args, float64(pct))
+			},
+		},
+		"parity":   newArgParity(getProc),
+		"uparity":  newArgParity(getUnproc),
+		"gzip":     newArgCompress(getProc, newGzip),
+		"ugzip":    newArgCompress(getUnproc, newGzip),
+		"zstd":     newArgCompress(getProc, newZstd),
+		"uzstd":    newArgCompress(getUnproc, newZstd),
+		"lz4":      newArgCompress(getProc, newLz4),
+		"ulz4":     newArgCompress(getUnproc, newLz4),
+		"xz":       newArgCompress(getProc, newXz),
+		"uxz":      newArgCompress(getUnproc, newXz),
+		"encrypt":  newArgEncrypt(getProc, false),
+		"cencrypt": newArgEncrypt(getProc, true),
+		"uencrypt": newArgEncrypt(getUnproc, false),
+		"compress": ap.ArgLambda{
+			Args: ap.Args{ap.ArgStr, ap.ArgOptional{ap.ArgInt, 0}},
+			Run: func(args []interface{}) (interface{}, error) {
+				var (
+					codec = args[0].(string)
+					level = args[1].(int)
+				)
+				comp, err := procs.NewCompress(codec, level)
+				if err != nil {
+					return nil, err
+				}
+				return comp.Proc(), nil
+			},
+		},
+		"ucompress": ap.ArgLambda{
+			Run: func([]interface{}) (interface{}, error) {
+				return procs.Ucompress, nil
 			},
 		},
-		"parity":  newArgParity(getProc),
-		"uparity": newArgParity(getUnproc),
-		"gzip":    newArgGzip(getProc),
-		"ugzip":   newArgGzip(getUnproc),
 		"sort": ap.ArgLambda{
 			Run: func([]interface{}) (interface{}, error) {
 				return &procs.Sort{}, nil
@@ -185,13 +414,65 @@ func (b builder) newArgProc(argProc, argDynp, argStore ap.Parser) ap.ArgFn {
 			},
 		},
 		"join": ap.ArgLambda{
+			Args: ap.Args{
+				ap.ArgStr,
+				ap.ArgOptional{ap.ArgBytes, uint64(0)},
+				ap.ArgOptional{ap.ArgInt, 0},
+				ap.ArgOptional{argSpill, false},
+			},
+			Run: func(args []interface{}) (interface{}, error) {
+				var (
+					path      = args[0].(string)
+					maxBytes  = args[1].(uint64)
+					maxChunks = args[2].(int)
+					spill     = args[3].(bool)
+				)
+				w, err := openOut(path)
+				if err != nil {
+					return nil, err
+				}
+				j := &joinProc{}
+				if maxBytes != 0 || maxChunks != 0 {
+					j.win = procs.NewSortWindow()
+					j.win.MaxBytes = int64(maxBytes)
+					j.win.MaxChunks = maxChunks
+					if spill {
+						j.win.Spill = b.tmp
+					}
+				}
+				j.build = func(rng *procs.ByteRange) procs.Proc {
+					return procs.NewWindowJoin(w, j.win, rng)
+				}
+				b.link.add(j)
+				return j, nil
+			},
+		},
+		"joinfile": ap.ArgLambda{
 			Args: ap.Args{ap.ArgStr},
 			Run: func(args []interface{}) (interface{}, error) {
 				var (
 					path = args[0].(string)
 				)
 				w, err := openOut(path)
-				return procs.NewJoin(w), err
+				if err != nil {
+					return nil, err
+				}
+				j := &joinProc{build: func(rng *procs.ByteRange) procs.Proc {
+					return procs.NewWindowJoin(w, nil, rng)
+				}}
+				if f, ok := w.(*os.File); ok {
+					fi, err := f.Stat()
+					if err != nil {
+						return nil, err
+					}
+					if fi.Mode().IsRegular() {
+						j.build = func(rng *procs.ByteRange) procs.Proc {
+							return procs.NewJoinFile(f, rng)
+						}
+					}
+				}
+				b.link.add(j)
+				return j, nil
 			},
 		},
 		"group": ap.ArgLambda{
@@ -215,8 +496,37 @@ func (b builder) newArgProc(argProc, argDynp, argStore ap.Parser) ap.ArgFn {
 	}
 }
 
+func (b builder) newMultiReader(icprs []interface{}, hedge float64) (
+	procs.Proc, error,
+) {
+	copiers := make([]stores.Copier, len(icprs))
+	for i, icp := range icprs {
+		copiers[i] = icp.(stores.Copier)
+	}
+	health := stores.NewReadHealth()
+	health.Hedge = hedge
+	if b.stats != nil {
+		health.OnUpdate = func(id interface{}, st stores.ReadStats) {
+			b.stats.Counter(id).Read = stats.ReadCounter{
+				Latency:   st.Latency,
+				Rate:      st.Rate,
+				Reads:     st.Reads,
+				Errors:    st.Errors,
+				OpenUntil: st.OpenUntil,
+			}
+		}
+	}
+	return stores.NewMultiReader(copiers, health)
+}
+
+func (b builder) setSplit(min, max uint) {
+	if b.hdr != nil {
+		b.hdr.SplitMin, b.hdr.SplitMax = min, max
+	}
+}
+
 func (b builder) newArgDynProc(argStore ap.Parser) ap.ArgFn {
-	newS := func(min, excl int, iress []interface{}) (procs.DynProcer, error) {
+	newS := func(min, excl int, iress []interface{}) (interface{}, error) {
 		qman := quota.NewMan()
 		if b.stats != nil {
 			qman.OnUse = func(res quota.Res, use, max uint64) {
@@ -225,14 +535,17 @@ func (b builder) newArgDynProc(argStore ap.Parser) ap.ArgFn {
 				cnt.Quota.Max = max
 			}
 		}
-		for _, ires := range iress {
-			res := ires.(quotaRes)
-			qman.AddResQuota(res.copier, res.max)
-		}
+		addQuotaRess(qman, iress)
 		cfg := stripe.Config{Min: min, Excl: excl}
 		return storestripe.New(cfg, qman)
 	}
 	argQuota := b.newArgQuota(b.newArgCopier(argStore, getProc))
+	return newArgStripe(argQuota, newS)
+}
+
+type newStripeFn func(min, excl int, iress []interface{}) (interface{}, error)
+
+func newArgStripe(argQuota ap.Parser, newS newStripeFn) ap.ArgFn {
 	return ap.ArgFn{
 		"mincopies": ap.ArgLambda{
 			Args: ap.Args{
@@ -266,6 +579,13 @@ func (b builder) newArgDynProc(argStore ap.Parser) ap.ArgFn {
 	}
 }
 
+func addQuotaRess(qman *quota.Man, iress []interface{}) {
+	for _, ires := range iress {
+		res := ires.(quotaRes)
+		qman.AddResQuota(res.res, res.max)
+	}
+}
+
 func (b builder) newArgStore() ap.ArgFn {
 	newDir := func(args []interface{}) stores.Dir {
 		var (
@@ -278,14 +598,28 @@ func (b builder) newArgStore() ap.ArgFn {
 		}
 		return stores.Dir{path, part}
 	}
-	return ap.ArgFn{
+	fns := ap.ArgFn{
 		"rclone": ap.ArgLambda{
 			Args: ap.Args{ap.ArgStr},
 			Run: func(args []interface{}) (interface{}, error) {
 				var (
 					remote = args[0].(string)
 				)
-				return stores.Rclone{remote, b.tmp}, nil
+				return stores.Rclone{Remote: remote, Tmp: b.tmp}, nil
+			},
+		},
+		"rclonerc": ap.ArgLambda{
+			Args: ap.Args{ap.ArgStr, ap.ArgOptional{ap.ArgStr, ""}},
+			Run: func(args []interface{}) (interface{}, error) {
+				var (
+					remote = args[0].(string)
+					url    = args[1].(string)
+				)
+				rcd := stores.SharedRcloneRcd
+				if url != "" {
+					rcd = stores.NewRcloneRcd(url)
+				}
+				return stores.RcloneRc{remote, b.tmp, rcd}, nil
 			},
 		},
 		"cp": ap.ArgLambda{
@@ -307,7 +641,122 @@ func (b builder) newArgStore() ap.ArgFn {
 				return stores.NewScp(host, dir), nil
 			},
 		},
+		"sftp": ap.ArgLambda{
+			Args: ap.Args{ap.ArgStr, ap.ArgStr, ap.ArgVariadic{ap.ArgInt}},
+			Run: func(args []interface{}) (interface{}, error) {
+				var (
+					host = args[0].(string)
+					dir  = newDir(args[1:])
+				)
+				return stores.NewSftp(host, dir)
+			},
+		},
+		"http": ap.ArgLambda{
+			Args: ap.Args{ap.ArgStr, ap.ArgVariadic{ap.ArgInt}},
+			Run: func(args []interface{}) (interface{}, error) {
+				var (
+					dir = newDir(args)
+				)
+				return stores.NewHttp(dir.Path, dir.Part, false), nil
+			},
+		},
+		"webdav": ap.ArgLambda{
+			Args: ap.Args{ap.ArgStr, ap.ArgVariadic{ap.ArgInt}},
+			Run: func(args []interface{}) (interface{}, error) {
+				var (
+					dir = newDir(args)
+				)
+				return stores.NewHttp(dir.Path, dir.Part, true), nil
+			},
+		},
+		"remote": ap.ArgLambda{
+			Args: ap.Args{ap.ArgStr},
+			Run: func(args []interface{}) (interface{}, error) {
+				var (
+					target = args[0].(string)
+				)
+				return stores.NewRemote(target), nil
+			},
+		},
+		"s3": ap.ArgLambda{
+			Args: ap.Args{
+				ap.ArgStr,
+				ap.ArgStr,
+				ap.ArgStr,
+				ap.ArgVariadic{ap.ArgInt},
+			},
+			Run: func(args []interface{}) (interface{}, error) {
+				var (
+					endpoint = args[0].(string)
+					bucket   = args[1].(string)
+					dir      = newDir(args[2:])
+				)
+				return stores.NewS3(endpoint, bucket, dir.Path, dir.Part), nil
+			},
+		},
+	}
+	fns["lscache"] = ap.ArgLambda{
+		Args: ap.Args{ap.ArgStr, argStoreDef{fns}},
+		Run: func(args []interface{}) (interface{}, error) {
+			var (
+				ttlStr = args[0].(string)
+				def    = args[1].(storeDef)
+			)
+			ttl, err := time.ParseDuration(ttlStr)
+			if err != nil {
+				return nil, err
+			}
+			return stores.NewLsCache(def.store, def.str, ttl)
+		},
 	}
+	fns["cache"] = ap.ArgLambda{
+		Args: ap.Args{ap.ArgStr, ap.ArgBytes, fns},
+		Run: func(args []interface{}) (interface{}, error) {
+			var (
+				dir   = args[0].(string)
+				max   = args[1].(uint64)
+				store = args[2].(stores.Store)
+			)
+			return stores.NewCache(dir, max, store), nil
+		},
+	}
+	fns["batch"] = ap.ArgLambda{
+		Args: ap.Args{ap.ArgInt, fns},
+		Run: func(args []interface{}) (interface{}, error) {
+			var (
+				size  = args[0].(int)
+				store = args[1].(stores.Store)
+			)
+			if size < 1 {
+				return nil, errors.New("batch size must be >= 1")
+			}
+			bat, ok := store.(stores.Batcher)
+			if !ok {
+				return nil, stores.ErrNoBatch
+			}
+			return bat.WithBatch(size)
+		},
+	}
+	return fns
+}
+
+// Parses a store along with its definition, identifying it across runs.
+type argStoreDef struct {
+	argStore ap.Parser
+}
+
+type storeDef struct {
+	store stores.Store
+	str   string
+}
+
+func (a argStoreDef) Parse(str string) (val interface{}, n int, err error) {
+	val, n, err = a.argStore.Parse(str)
+	if err != nil {
+		return
+	}
+	val = storeDef{val.(stores.Store), strings.TrimSpace(str[:n])}
+	return
 }
 
 func (b builder) newArgCopier(argStore ap.Parser, getProc getProcFn) ap.Parser {
@@ -335,38 +784,38 @@ func (b builder) newArgCopier(argStore ap.Parser, getProc getProcFn) ap.Parser {
 	}
 }
 
-func (b builder) newArgQuota(argCopier ap.Parser) ap.Parser {
+func (b builder) newArgQuota(argRes ap.Parser) ap.Parser {
 	argQuotaMax := ap.ArgPair{
-		Left:  argCopier,
+		Left:  argRes,
 		Right: ap.ArgBytes,
-		Run: func(icp, ibytes interface{}) (interface{}, error) {
+		Run: func(ires, ibytes interface{}) (interface{}, error) {
 			qr := quotaRes{
-				copier: icp.(stores.Copier),
-				max:    ibytes.(uint64),
+				res: ires.(quota.Res),
+				max: ibytes.(uint64),
 			}
 			return qr, nil
 		},
 	}
-	argRes := ap.ArgFilter{
-		Parser: ap.ArgOr{argQuotaMax, argCopier},
+	argQuotaRes := ap.ArgFilter{
+		Parser: ap.ArgOr{argQuotaMax, argRes},
 		Filter: func(val interface{}) (interface{}, error) {
-			if cp, ok := val.(stores.Copier); ok {
+			if res, ok := val.(quota.Res); ok {
 				val = quotaRes{
-					copier: cp,
-					max:    quota.Unlimited,
+					res: res,
+					max: quota.Unlimited,
 				}
 			}
 			return val, nil
 		},
 	}
 	if b.stats == nil {
-		return argRes
+		return argQuotaRes
 	}
 	return ap.ArgFilter{
-		Parser: argRes,
+		Parser: argQuotaRes,
 		Filter: func(val interface{}) (interface{}, error) {
 			res := val.(quotaRes)
-			cnt := b.stats.Counter(res.copier.Id())
+			cnt := b.stats.Counter(res.res.Id())
 			cnt.Quota.Max = res.max
 			return val, nil
 		},
@@ -387,6 +836,21 @@ func (r quotaInitReport) Ls() ([]stores.LsEntry, error) {
 	return r.lser.Ls()
 }
 
+func (r quotaInitReport) LsCached(fn func(added, removed []stores.LsEntry)) (
+	[]stores.LsEntry, error,
+) {
+	cl, ok := r.lser.(stores.CachedLister)
+	if !ok {
+		return r.Ls()
+	}
+	cnt := r.getCounter()
+	cnt.Quota.Init = true
+	defer func() {
+		cnt.Quota.Init = false
+	}()
+	return cl.LsCached(fn)
+}
+
 func newArgParity(getProc getProcFn) ap.Parser {
 	return ap.ArgLambda{
 		Args: ap.Args{ap.ArgInt, ap.ArgInt},
@@ -404,10 +868,43 @@ func newArgParity(getProc getProcFn) ap.Parser {
 	}
 }
 
-func newArgGzip(getProc getProcFn) ap.Parser {
+type newCompressFn func(level int) procs.ProcUnprocer
+
+var (
+	newGzip newCompressFn = func(l int) procs.ProcUnprocer { return procs.Gzip{l} }
+	newZstd newCompressFn = func(l int) procs.ProcUnprocer { return procs.Zstd{l} }
+	newLz4  newCompressFn = func(l int) procs.ProcUnprocer { return procs.Lz4{l} }
+	newXz   newCompressFn = func(l int) procs.ProcUnprocer { return procs.Xz{l} }
+)
+
+func newArgCompress(getProc getProcFn, newc newCompressFn) ap.Parser {
 	return ap.ArgLambda{
-		Run: func([]interface{}) (interface{}, error) {
-			return getProc(procs.Gzip{}), nil
+		Args: ap.Args{ap.ArgOptional{ap.ArgInt, 0}},
+		Run: func(args []interface{}) (interface{}, error) {
+			var (
+				level = args[0].(int)
+			)
+			return getProc(newc(level)), nil
+		},
+	}
+}
+
+func newArgEncrypt(getProc getProcFn, convergent bool) ap.Parser {
+	return ap.ArgLambda{
+		Args: ap.Args{ap.ArgStr},
+		Run: func(args []interface{}) (interface{}, error) {
+			var (
+				keyPath = args[0].(string)
+			)
+			key, err := ioutil.ReadFile(keyPath)
+			if err != nil {
+				return nil, err
+			}
+			enc, err := procs.NewEncrypt(key, convergent)
+			if err != nil {
+				return nil, err
+			}
+			return getProc(enc), nil
 		},
 	}
 }
@@ -444,8 +941,8 @@ func getUnproc(p procs.ProcUnprocer) procs.Proc {
 }
 
 type quotaRes struct {
-	max    uint64
-	copier stores.Copier
+	max uint64
+	res quota.Res
 }
 
 func uintBytes(val interface{}) uint {
@@ -457,6 +954,25 @@ func uintBytes(val interface{}) uint {
 	return uint(i)
 }
 
+// Like ArgInt, but for ArgOr to try the next parser on anything else.
+type argMaybeInt struct{}
+
+func (argMaybeInt) Parse(str string) (interface{}, int, error) {
+	res, n, err := ap.ArgInt.Parse(str)
+	if err != nil {
+		err = ap.ErrInvalidSyntax
+	}
+	return res, n, err
+}
+
+var argSpill = ap.ArgFn{
+	"spill": ap.ArgLambda{
+		Run: func([]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	},
+}
+
 func openOut(path string) (io.WriteCloser, error) {
 	if path == "-" {
 		return nopWriteCloser{os.Stdout}, nil
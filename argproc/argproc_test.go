@@ -1,12 +1,238 @@
 package argproc_test
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/Roman2K/scat"
 	"github.com/Roman2K/scat/argproc"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/stores"
+	"github.com/Roman2K/scat/testutil"
+	assert "github.com/stretchr/testify/require"
 )
 
 func TestNew(t *testing.T) {
 	// just test that it compiles
 	argproc.New(nil, nil)
 }
+
+func TestStoreDeleteProc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, testutil.Hash1.Hex)
+	err = ioutil.WriteFile(path, []byte("a"), 0644)
+	assert.NoError(t, err)
+
+	res, _, err := argproc.New(nil, nil).Parse("rmcp(" + dir + ")")
+	assert.NoError(t, err)
+	c := scat.NewChunk(0, nil)
+	c.SetHash(testutil.Hash1.Hash)
+	err = procs.Process(res.(procs.Proc), c)
+	assert.NoError(t, err)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestEncryptProc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyPath := filepath.Join(dir, "key")
+	err = ioutil.WriteFile(keyPath, make([]byte, 32), 0600)
+	assert.NoError(t, err)
+
+	res, _, err := argproc.New(nil, nil).Parse(
+		"cencrypt(" + keyPath + ") | uencrypt(" + keyPath + ")",
+	)
+	assert.NoError(t, err)
+	c := scat.NewChunk(0, scat.BytesData("abc"))
+	chunks, err := testutil.ReadChunks(res.(procs.Proc).Process(c))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(chunks))
+	b, err := chunks[0].Data().Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", string(b))
+
+	// missing key file
+	_, _, err = argproc.New(nil, nil).Parse("encrypt(" + dir + "/none)")
+	assert.Error(t, err)
+}
+
+func TestCompressProcs(t *testing.T) {
+	for _, name := range []string{"gzip", "zstd", "lz4", "xz"} {
+		for _, str := range []string{
+			name + " | u" + name,
+			name + "(9) | u" + name,
+			name + " 1 | u" + name + " 1",
+		} {
+			res, _, err := argproc.New(nil, nil).Parse(str)
+			assert.NoError(t, err)
+			c := scat.NewChunk(0, scat.BytesData("abc"))
+			chunks, err := testutil.ReadChunks(res.(procs.Proc).Process(c))
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(chunks))
+			b, err := chunks[0].Data().Bytes()
+			assert.NoError(t, err)
+			assert.Equal(t, "abc", string(b))
+		}
+	}
+}
+
+func TestCompressHeaderProcs(t *testing.T) {
+	for _, str := range []string{
+		"compress gzip | ucompress",
+		"compress(zstd 19) | ucompress",
+		"zstd | ucompress",
+	} {
+		res, _, err := argproc.New(nil, nil).Parse(str)
+		assert.NoError(t, err)
+		c := scat.NewChunk(0, scat.BytesData("abc"))
+		chunks, err := testutil.ReadChunks(res.(procs.Proc).Process(c))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(chunks))
+		b, err := chunks[0].Data().Bytes()
+		assert.NoError(t, err)
+		assert.Equal(t, "abc", string(b))
+	}
+	_, _, err := argproc.New(nil, nil).Parse("compress foo")
+	assert.Error(t, err)
+}
+
+func TestJoinWindow(t *testing.T) {
+	argProc := argproc.New(nil, nil)
+	for _, str := range []string{
+		"uindex | join -",
+		"uindex | join - 64mib",
+		"uindex | join - 0 16",
+		"uindex | join - 64mib 16 spill",
+	} {
+		_, _, err := argProc.Parse(str)
+		assert.NoError(t, err, str)
+	}
+	_, _, err := argProc.Parse("uindex | join - 64mib 16 foo")
+	assert.Error(t, err)
+
+	// linked per uindex, in either order
+	for _, str := range []string{
+		"uindex | uindex | join -",
+		"uindex 1 | join - 16 | uindex 2 | join - 0 16",
+		"join - 16 | uindex 1",
+		"uindex | join - 16",
+	} {
+		_, _, err := argProc.Parse(str)
+		assert.NoError(t, err, str)
+	}
+	_, _, err = argProc.Parse("uindex | join - 16 | join - 0 16")
+	assert.Error(t, err)
+	_, _, err = argProc.Parse("join - 16 | join - 0 16 | uindex")
+	assert.Error(t, err)
+}
+
+func TestJoinRange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	idx := testutil.Hashes[0].Hex + " 4 0\n" + testutil.Hashes[1].Hex + " 4 4\n"
+	for i, str := range []string{
+		"uindex 2 4 | join %s",
+		"join %s | uindex 2 4",
+	} {
+		path := filepath.Join(dir, fmt.Sprintf("out%d", i))
+		res, _, err := argproc.New(nil, nil).Parse(fmt.Sprintf(str, path))
+		assert.NoError(t, err, str)
+		chain := res.(procs.Chain)
+		uindex, join := chain[0], chain[1]
+		if i == 1 {
+			uindex, join = join, uindex
+		}
+		entries, err := testutil.ReadChunks(
+			uindex.Process(scat.NewChunk(0, scat.BytesData(idx))),
+		)
+		assert.NoError(t, err, str)
+		assert.Equal(t, 2, len(entries))
+		for i, data := range []string{"abcd", "efgh"} {
+			c := entries[i].WithData(scat.BytesData(data))
+			_, err = testutil.ReadChunks(join.Process(c))
+			assert.NoError(t, err, str)
+		}
+		assert.NoError(t, join.Finish(), str)
+		b, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "cdef", string(b), str)
+	}
+}
+
+func TestLsCacheStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	t.Setenv("XDG_CACHE_HOME", dir)
+
+	res, _, err := argproc.NewStores(nil).Parse(
+		"a=lscache(1h  cp(/some/dir 2) ) b=lscache(0s cp(/some/dir 2))",
+	)
+	assert.NoError(t, err)
+	ss := res.([]stores.NamedStore)
+	c1 := ss[0].Store.(*stores.LsCache)
+	c2 := ss[1].Store.(*stores.LsCache)
+	assert.Equal(t, time.Hour, c1.TTL)
+	assert.Equal(t, time.Duration(0), c2.TTL)
+	assert.Equal(t, stores.Cp{"/some/dir", stores.StrPart{2}}, c1.Store)
+	assert.Equal(t, c1.Path, c2.Path)
+	key := sha256.Sum256([]byte("cp(/some/dir 2)"))
+	assert.Equal(t, filepath.Join(dir, "scat", "ls", fmt.Sprintf("%x", key)),
+		c1.Path,
+	)
+
+	// through cache
+	res, _, err = argproc.NewStores(nil).Parse(
+		"a=cache(" + dir + " 1mib lscache(1h cp(/some/dir 2)))",
+	)
+	assert.NoError(t, err)
+	_, ok := res.([]stores.NamedStore)[0].Store.(stores.CachedLister)
+	assert.True(t, ok)
+	_, _, err = argproc.NewStores(nil).Parse(
+		"a=batch(2 cache(" + dir + " 1mib cp(/some/dir 2)))",
+	)
+	assert.Error(t, err)
+}
+
+func TestHedgedMultiReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, testutil.Hash1.Hex)
+	err = ioutil.WriteFile(path, []byte("a"), 0644)
+	assert.NoError(t, err)
+
+	res, _, err := argproc.New(nil, nil).Parse(
+		"multireader(95 a=cp(" + dir + ") b=cp(" + dir + "))",
+	)
+	assert.NoError(t, err)
+	c := scat.NewChunk(0, nil)
+	c.SetHash(testutil.Hash1.Hash)
+	chunks, err := testutil.ReadChunks(res.(procs.Proc).Process(c))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(chunks))
+	b, err := chunks[0].Data().Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(b))
+
+	// out of range
+	_, _, err = argproc.New(nil, nil).Parse("multireader(0 a=cp(" + dir + "))")
+	assert.Error(t, err)
+
+	// after stores
+	_, _, err = argproc.New(nil, nil).Parse("multireader(a=cp(" + dir + ") 95)")
+	assert.Error(t, err)
+}
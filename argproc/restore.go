@@ -0,0 +1,244 @@
+package argproc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	ap "github.com/Roman2K/scat/argparse"
+)
+
+var errRestoreNoIndex = errors.New(
+	"no index proc: restoring starts from an index",
+)
+
+type rawProc struct {
+	name, args string
+}
+
+func (p rawProc) String() string {
+	return p.name + "(" + p.args + ")"
+}
+
+// Keeps arguments as-is, not to run anything while parsing.
+var argRaw = argRawParser{}
+
+type argRawParser struct{}
+
+func (argRawParser) Parse(str string) (interface{}, int, error) {
+	return []interface{}{strings.TrimSpace(str)}, len(str), nil
+}
+
+func newArgRawFn(names ap.ArgFn, run func(rawProc) interface{}) ap.ArgFn {
+	fns := make(ap.ArgFn, len(names))
+	for k := range names {
+		name := k
+		fns[name] = ap.ArgLambda{
+			Args: argRaw,
+			Run: func(args []interface{}) (interface{}, error) {
+				return run(rawProc{name, args[0].(string)}), nil
+			},
+		}
+	}
+	return fns
+}
+
+type restore struct {
+	inverses   map[string]string
+	out        string
+	argProc    ap.Parser
+	argDynProc ap.Parser
+	storeFns   ap.ArgFn
+	indexed    bool
+}
+
+// Derives the restore proc string of a backup proc string: inverses of its
+// procs in reverse order, starting from the index and joining into out. Procs
+// without a known inverse, like cmd, must have one in inverses, keyed by proc
+// name.
+func Restore(str string, inverses map[string]string, out string) (
+	string, error,
+) {
+	r := &restore{inverses: inverses, out: out}
+	r.init()
+	res, _, err := r.argProc.Parse(str)
+	if err != nil {
+		return "", err
+	}
+	steps, err := r.chain(res.([]interface{}))
+	if err != nil {
+		return "", err
+	}
+	if !r.indexed {
+		return "", errRestoreNoIndex
+	}
+	return strings.Join(append([]string{"uindex"}, steps...), " | "), nil
+}
+
+func (r *restore) init() {
+	b := builder{link: &joinLink{}}
+	argStore := b.newArgStore()
+	procFns := b.newArgProcFns(nil, nil, argStore)
+	r.storeFns = argStore
+
+	argProc := make(ap.ArgOr, 2)
+	argProc[0] = ap.ArgLambda{
+		Brackets: chainBrackets,
+		Args:     ap.ArgPiped{Arg: argProc, Nest: chainBrackets},
+		Run: func(args []interface{}) (interface{}, error) {
+			return args, nil
+		},
+	}
+	argProc[1] = newArgRawFn(procFns, func(p rawProc) interface{} {
+		return p
+	})
+	r.argProc = ap.ArgPiped{Arg: argProc, Nest: chainBrackets}
+
+	rawStore := newArgRawFn(argStore, func(p rawProc) interface{} {
+		return p.String()
+	})
+	rawCopier := ap.ArgPair{
+		Left:  ap.ArgStr,
+		Right: rawStore,
+		Run: func(id, store interface{}) (interface{}, error) {
+			return id.(string) + "=" + store.(string), nil
+		},
+	}
+	rawQuota := ap.ArgOr{
+		ap.ArgPair{
+			Left:  rawCopier,
+			Right: ap.ArgBytes,
+			Run: func(copier, _ interface{}) (interface{}, error) {
+				return copier, nil
+			},
+		},
+		rawCopier,
+	}
+	r.argDynProc = newArgStripe(rawQuota, func(_, _ int, copiers []interface{}) (
+		interface{}, error,
+	) {
+		strs := make([]string, len(copiers))
+		for i, c := range copiers {
+			strs[i] = c.(string)
+		}
+		return "multireader(" + strings.Join(strs, " ") + ")", nil
+	})
+}
+
+func (r *restore) chain(items []interface{}) (steps []string, err error) {
+	for _, item := range items {
+		var step string
+		switch v := item.(type) {
+		case []interface{}:
+			var sub []string
+			sub, err = r.chain(v)
+			step = chainStr(sub)
+		case rawProc:
+			step, err = r.proc(v)
+		}
+		if err != nil {
+			return
+		}
+		if step != "" {
+			steps = append(steps, step)
+		}
+	}
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return
+}
+
+func chainStr(steps []string) string {
+	if len(steps) == 0 {
+		return ""
+	}
+	return "{ " + strings.Join(steps, " | ") + " }"
+}
+
+func (r *restore) proc(p rawProc) (string, error) {
+	if inv, ok := r.inverses[p.name]; ok {
+		return inv, nil
+	}
+	if _, ok := r.storeFns[p.name]; ok {
+		return "u" + p.String(), nil
+	}
+	switch p.name {
+	case "index":
+		if r.indexed {
+			return "", errors.New("multiple index procs")
+		}
+		r.indexed = true
+		return "", nil
+	case "split", "split2":
+		return "join " + r.out, nil
+	case "checksum":
+		// Before index: computes the hash of data the index will refer to
+		if !r.indexed {
+			return "", nil
+		}
+		return "uchecksum", nil
+	case "group", "sort":
+		// Index entries are ungrouped, in order
+		return "", nil
+	case "sparse":
+		// Holes get restored by join
+		return "", nil
+	case "parity":
+		var ndata, nparity int
+		_, err := fmt.Sscanf(p.args, "%d %d", &ndata, &nparity)
+		if err != nil {
+			return "", fmt.Errorf("parity: %v", err)
+		}
+		return fmt.Sprintf("group %d | uparity %d %d",
+			ndata+nparity, ndata, nparity,
+		), nil
+	case "gzip", "zstd", "lz4", "xz":
+		return "u" + p.name, nil
+	case "compress":
+		return "ucompress", nil
+	case "encrypt", "cencrypt":
+		return "uencrypt " + p.args, nil
+	case "backlog":
+		return r.nested(p, r.backlogProc)
+	case "concur":
+		return r.nested(p, r.concurProc)
+	}
+	return "", fmt.Errorf("no known inverse of %s, supply one", p.name)
+}
+
+func (r *restore) nested(p rawProc, inner func(string) (string, error)) (
+	string, error,
+) {
+	var n int
+	_, err := fmt.Sscanf(p.args, "%d", &n)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", p.name, err)
+	}
+	rest := strings.TrimSpace(strings.TrimLeft(p.args, "0123456789"))
+	inv, err := inner(rest)
+	if err != nil || inv == "" {
+		return "", err
+	}
+	return fmt.Sprintf("backlog %d %s", n, inv), nil
+}
+
+func (r *restore) backlogProc(str string) (string, error) {
+	res, _, err := r.argProc.Parse(str)
+	if err != nil {
+		return "", err
+	}
+	steps, err := r.chain(res.([]interface{}))
+	if err != nil || len(steps) != 1 {
+		return chainStr(steps), err
+	}
+	return steps[0], nil
+}
+
+func (r *restore) concurProc(str string) (string, error) {
+	res, _, err := r.argDynProc.Parse(str)
+	if err != nil {
+		return "", err
+	}
+	return res.(string), nil
+}
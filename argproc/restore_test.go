@@ -0,0 +1,60 @@
+package argproc_test
+
+import (
+	"testing"
+
+	"github.com/Roman2K/scat/argproc"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestRestore(t *testing.T) {
+	const backup = `split | backlog 8 {
+		checksum
+		| index foo_index
+		| gzip 9
+		| parity 2 1
+		| checksum
+		| cencrypt key
+		| group 3
+		| concur 4 stripe(1 2
+				a=rclone(drive:tmp)=7gib
+				b=scp(bankmon tmp)
+			)
+	}`
+	res, err := argproc.Restore(backup, nil, "-")
+	assert.NoError(t, err)
+	assert.Equal(t, "uindex | backlog 8 { "+
+		"backlog 4 multireader(a=rclone(drive:tmp) b=scp(bankmon tmp))"+
+		" | uencrypt key"+
+		" | uchecksum"+
+		" | group 3 | uparity 2 1"+
+		" | ugzip"+
+		" } | join -",
+		res,
+	)
+
+	// single store
+	res, err = argproc.Restore("split | checksum | index - | cp(dir 2)",
+		nil, "-",
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "uindex | ucp(dir 2) | join -", res)
+	_, _, err = argproc.New(nil, nil).Parse(res)
+	assert.NoError(t, err)
+
+	// no index
+	_, err = argproc.Restore("split | checksum | cp(dir)", nil, "-")
+	assert.Error(t, err)
+
+	// no known inverse
+	const gpg = "split | checksum | index - | cmd gpg -e | cp(dir)"
+	_, err = argproc.Restore(gpg, nil, "-")
+	assert.Error(t, err)
+	res, err = argproc.Restore(gpg, map[string]string{"cmd": "cmd gpg -d"}, "-")
+	assert.NoError(t, err)
+	assert.Equal(t, "uindex | ucp(dir) | cmd gpg -d | join -", res)
+
+	// syntax error
+	_, err = argproc.Restore("split | foo", nil, "-")
+	assert.Error(t, err)
+}
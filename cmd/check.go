@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Roman2K/scat/stores/check"
+	"github.com/Roman2K/scat/tmpdedup"
+)
+
+var checkExitCodes = map[check.Status]int{
+	check.Degraded:      3,
+	check.Unrecoverable: 4,
+}
+
+func checkMode(args []string) (err error) {
+	fl := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	var (
+		min       = fl.Int("min", 1, "required number of copies of each chunk")
+		parityStr = fl.String("parity", "", "check parity groups: "+parityDesc)
+	)
+	parseModeArgs(fl, args[1:], modeUsage{
+		args: "<stores> <index>",
+		descs: [][2]string{
+			{"<stores>", storesDesc},
+			{"<index>", "index file, - for stdin"},
+			{"", fmt.Sprintf("exits with %d if degraded, %d if unrecoverable",
+				checkExitCodes[check.Degraded],
+				checkExitCodes[check.Unrecoverable],
+			)},
+		},
+	}, 2)
+
+	chk := check.Check{Min: *min}
+	if *parityStr != "" {
+		chk.Data, chk.Parity, err = parseParity(*parityStr)
+		if err != nil {
+			return
+		}
+	}
+
+	tmp, err := tmpdedup.TempDir("")
+	if err != nil {
+		return
+	}
+	defer tmp.Finish()
+
+	ss, err := parseStores(tmp, fl.Arg(0))
+	if err != nil {
+		return
+	}
+	r, err := openIn(fl.Arg(1))
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	res, err := chk.Run(ss, r)
+	if err != nil {
+		return
+	}
+	for _, h := range res.Missing {
+		fmt.Fprintf(os.Stdout, "missing: %x\n", h)
+	}
+	for _, u := range res.Under {
+		fmt.Fprintf(os.Stdout, "under: %x copies=%d\n", u.Hash, u.Copies)
+	}
+	printGroups := func(status check.Status, groups []check.Group) {
+		gsize := chk.Data + chk.Parity
+		for _, g := range groups {
+			fmt.Fprintf(os.Stdout, "%s: group %d missing=%d/%d\n",
+				status, g.Num, g.Missing, gsize,
+			)
+		}
+	}
+	printGroups(check.Degraded, res.Degraded)
+	printGroups(check.Unrecoverable, res.Unrecoverable)
+	status := res.Status()
+	fmt.Fprintf(os.Stdout, "%s: chunks=%d missing=%d under=%d\n",
+		status, res.Chunks, len(res.Missing), len(res.Under),
+	)
+	if code, ok := checkExitCodes[status]; ok {
+		err = exitCodeErr{fmt.Errorf("check: %s", status), code}
+	}
+	return
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Roman2K/scat/stores/gc"
+	"github.com/Roman2K/scat/tmpdedup"
+	humanize "github.com/dustin/go-humanize"
+)
+
+func gcMode(args []string) (err error) {
+	fl := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	dryRun := fl.Bool("n", false, "dry run: only report what would be deleted")
+	parseModeArgs(fl, args[1:], modeUsage{
+		args: "<stores> <index>...",
+		descs: [][2]string{
+			{"<stores>", storesDesc},
+			{"<index>", "live index file, - for stdin"},
+		},
+	}, 2)
+
+	tmp, err := tmpdedup.TempDir("")
+	if err != nil {
+		return
+	}
+	defer tmp.Finish()
+
+	ss, err := parseStores(tmp, fl.Arg(0))
+	if err != nil {
+		return
+	}
+	live := gc.Live{}
+	for _, path := range fl.Args()[1:] {
+		err = addLiveIndex(live, path)
+		if err != nil {
+			return
+		}
+	}
+	reports, err := gc.Collect(live, ss, *dryRun)
+	verb := "deleted"
+	if *dryRun {
+		verb = "reclaimable"
+	}
+	for _, rep := range reports {
+		fmt.Fprintf(os.Stdout, "%15s\t%8d chunks\t%10s %s\n",
+			rep.Id, rep.Count, humanize.IBytes(uint64(rep.Size)), verb,
+		)
+	}
+	return
+}
+
+func addLiveIndex(live gc.Live, path string) (err error) {
+	r, err := openIn(path)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	return live.AddIndex(r)
+}
@@ -14,8 +14,10 @@ import (
 	"github.com/Roman2K/scat/ansirefresh"
 	"github.com/Roman2K/scat/argparse"
 	"github.com/Roman2K/scat/argproc"
+	"github.com/Roman2K/scat/index"
 	"github.com/Roman2K/scat/procs"
 	"github.com/Roman2K/scat/stats"
+	"github.com/Roman2K/scat/stores"
 	"github.com/Roman2K/scat/tmpdedup"
 )
 
@@ -30,12 +32,28 @@ func main() {
 		if exit, ok := err.(*exec.ExitError); ok {
 			fmt.Fprintf(os.Stderr, "stderr:\n%s\n", exit.Stderr)
 		}
-		os.Exit(1)
+		code := 1
+		if exit, ok := err.(exitCodeErr); ok {
+			code = exit.code
+		}
+		os.Exit(code)
 	}
 }
 
+type exitCodeErr struct {
+	error
+	code int
+}
+
 func start() (err error) {
 	rand.Seed(time.Now().UnixNano())
+	defer stores.SharedRcloneRcd.Stop()
+
+	if len(os.Args) > 1 {
+		if run, ok := modes[os.Args[1]]; ok {
+			return run(os.Args[1:])
+		}
+	}
 
 	args := cmdArgs{}
 	args.Parse(os.Args)
@@ -45,6 +63,18 @@ func start() (err error) {
 		return
 	}
 
+	var hdr *index.Header
+	if args.header || args.describe {
+		hdr = &index.Header{Version: index.Version}
+	}
+	if args.describe {
+		hdr.Time = time.Now()
+		hdr.Proc = args.procStr
+	}
+	return runProc(args.procStr, args.stats, hdr)
+}
+
+func runProc(procStr string, withStats bool, hdr *index.Header) (err error) {
 	tmp, err := tmpdedup.TempDir("")
 	if err != nil {
 		return
@@ -52,15 +82,15 @@ func start() (err error) {
 	defer tmp.Finish()
 
 	var statsd *stats.Statsd
-	if args.stats {
+	if withStats {
 		statsd = stats.New()
 		w := ansirefresh.NewWriter(os.Stderr)
 		t := ansirefresh.NewWriteTicker(w, statsd, 500*time.Millisecond)
 		defer t.Stop()
 	}
 
-	argProc := argproc.New(tmp, statsd)
-	res, _, err := argProc.Parse(args.procStr)
+	argProc := argproc.NewHeader(tmp, statsd, hdr)
+	res, _, err := argProc.Parse(procStr)
 	if err != nil {
 		return
 	}
@@ -71,9 +101,11 @@ func start() (err error) {
 }
 
 type cmdArgs struct {
-	procStr string
-	stats   bool
-	version bool
+	procStr  string
+	stats    bool
+	header   bool
+	describe bool
+	version  bool
 }
 
 func (a *cmdArgs) Parse(args []string) {
@@ -83,13 +115,21 @@ func (a *cmdArgs) Parse(args []string) {
 	}
 	fl := flag.NewFlagSet(name, flag.ContinueOnError)
 	fl.BoolVar(&a.stats, "stats", false, "print stats: rates, quotas, etc.")
+	fl.BoolVar(&a.header, "header", false,
+		"write index header, trailer and offsets, unreadable by older scat",
+	)
+	fl.BoolVar(&a.describe, "describe", false,
+		"record time and proc string in the index header, implies -header",
+	)
 	fl.BoolVar(&a.version, "version", false, "show version")
 	fl.SetOutput(ioutil.Discard)
 	usage := func(w io.Writer) {
 		fmt.Fprintf(w, "usage: %s [options] <proc>\n", name)
+		fmt.Fprintf(w, "       %s <mode> [options] <args>...\n", name)
 		fmt.Fprintln(w)
 		fmt.Fprintf(w, "\t<proc>\tproc string\n")
 		fmt.Fprintf(w, "\t\tsee %s\n", url)
+		fmt.Fprintf(w, "\t<mode>\t%s\n", modeNames())
 		fmt.Fprintln(w)
 		fmt.Fprintf(w, "options:\n")
 		fl.SetOutput(w)
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/argproc"
+	"github.com/Roman2K/scat/index"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/stores"
+	"github.com/Roman2K/scat/tmpdedup"
+)
+
+type modeFn func(args []string) error
+
+var modes = map[string]modeFn{
+	"check":     checkMode,
+	"gc":        gcMode,
+	"rebalance": rebalanceMode,
+	"repair":    repairMode,
+	"restore":   restoreMode,
+	"scrub":     scrubMode,
+	"serve":     serveMode,
+}
+
+func modeNames() string {
+	names := make([]string, 0, len(modes))
+	for name := range modes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+type modeUsage struct {
+	args  string
+	descs [][2]string
+}
+
+func parseModeArgs(fl *flag.FlagSet, args []string, usg modeUsage,
+	minArgs int,
+) {
+	name := fl.Name()
+	fl.SetOutput(ioutil.Discard)
+	usage := func(w io.Writer) {
+		fmt.Fprintf(w, "usage: %s %s [options] %s\n", os.Args[0], name, usg.args)
+		fmt.Fprintln(w)
+		for _, d := range usg.descs {
+			fmt.Fprintf(w, "\t%s\t%s\n", d[0], d[1])
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "options:\n")
+		fl.SetOutput(w)
+		defer fl.SetOutput(ioutil.Discard)
+		fl.PrintDefaults()
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "see %s\n", url)
+	}
+	err := fl.Parse(args)
+	if err != nil || fl.NArg() < minArgs {
+		w, code := os.Stderr, 2
+		if err == flag.ErrHelp {
+			w, code = os.Stdout, 0
+		}
+		usage(w)
+		os.Exit(code)
+	}
+}
+
+const storesDesc = "named stores, ex: \"hdd=cp(/mnt/hdd) drive=rclone(drive:)\""
+
+func parseStores(tmp *tmpdedup.Dir, str string) (
+	ss []stores.NamedStore, err error,
+) {
+	res, _, err := argproc.NewStores(tmp).Parse(str)
+	if err != nil {
+		return
+	}
+	ss = res.([]stores.NamedStore)
+	return
+}
+
+func openIn(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+func readIndexGroups(paths []string, size int) (
+	groups [][]*scat.Chunk, err error,
+) {
+	if size < 1 {
+		err = errors.New("group size must be >= 1")
+		return
+	}
+	for _, path := range paths {
+		chunks, err := readIndexChunks(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(chunks)%size != 0 {
+			err = fmt.Errorf("%s: %d entries not divisible by group size %d",
+				path, len(chunks), size,
+			)
+			return nil, err
+		}
+		for i := 0; i < len(chunks); i += size {
+			groups = append(groups, chunks[i:i+size])
+		}
+	}
+	return
+}
+
+func readIndexChunks(path string) (chunks []*scat.Chunk, err error) {
+	r, err := openIn(path)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	scan := index.NewScanner(0, r)
+	for scan.Next() {
+		chunks = append(chunks, scan.Chunk())
+	}
+	err = scan.Err()
+	return
+}
+
+const stripeDesc = "stripe(<min> <excl> <stores>...) or " +
+	"mincopies(<min> <stores>...)"
+
+func parseStripeSpec(tmp *tmpdedup.Dir, str string) (
+	spec argproc.StripeSpec, err error,
+) {
+	res, _, err := argproc.NewStripeSpec(tmp).Parse(str)
+	if err != nil {
+		return
+	}
+	spec = res.(argproc.StripeSpec)
+	return
+}
+
+func parseProc(tmp *tmpdedup.Dir, str string) (proc procs.Proc, err error) {
+	res, _, err := argproc.New(tmp, nil).Parse(str)
+	if err != nil {
+		return
+	}
+	proc = res.(procs.Proc)
+	return
+}
+
+const parityDesc = "\"<ndata> <nparity>\" as in parity(...)"
+
+func parseParity(str string) (ndata, nparity int, err error) {
+	_, err = fmt.Sscanf(str, "%d %d", &ndata, &nparity)
+	if err != nil {
+		err = fmt.Errorf("invalid parity %q: %v", str, err)
+	}
+	return
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Roman2K/scat/stores"
+	"github.com/Roman2K/scat/stores/rebalance"
+	"github.com/Roman2K/scat/tmpdedup"
+	humanize "github.com/dustin/go-humanize"
+)
+
+func rebalanceMode(args []string) (err error) {
+	fl := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	var (
+		dryRun  = fl.Bool("n", false, "dry run: only report what would be done")
+		drain   = fl.Bool("drain", false, "delete chunks from stores not in <stripe>")
+		group   = fl.Int("group", 1, "index entries per stripe, as in group(n)")
+		fromStr = fl.String("from", "", "other stores to read chunks from")
+	)
+	parseModeArgs(fl, args[1:], modeUsage{
+		args: "<stripe> <index>...",
+		descs: [][2]string{
+			{"<stripe>", stripeDesc},
+			{"<index>", "index file, - for stdin"},
+		},
+	}, 2)
+
+	tmp, err := tmpdedup.TempDir("")
+	if err != nil {
+		return
+	}
+	defer tmp.Finish()
+
+	spec, err := parseStripeSpec(tmp, fl.Arg(0))
+	if err != nil {
+		return
+	}
+	var from []stores.NamedStore
+	if *fromStr != "" {
+		from, err = parseStores(tmp, *fromStr)
+		if err != nil {
+			return
+		}
+	}
+	groups, err := readIndexGroups(fl.Args()[1:], *group)
+	if err != nil {
+		return
+	}
+	rb := rebalance.Rebalance{
+		Stripe: spec.Config,
+		Qman:   spec.Qman,
+		Others: from,
+		Drain:  *drain,
+		DryRun: *dryRun,
+	}
+	res, err := rb.Run(groups)
+	printRebalanceResult(res)
+	if err == nil && len(res.Failed) > 0 {
+		err = fmt.Errorf("%d chunks failed to copy", len(res.Failed))
+	}
+	return
+}
+
+func printRebalanceResult(res rebalance.Result) {
+	for _, rep := range res.Reports {
+		fmt.Fprintf(os.Stdout, "%15s\t+%d chunks\t%10s\t-%d chunks\t%10s\n",
+			rep.Id,
+			rep.Copied, humanize.IBytes(uint64(rep.CopiedSize)),
+			rep.Deleted, humanize.IBytes(uint64(rep.DeletedSize)),
+		)
+	}
+	for _, h := range res.Rebuilt {
+		fmt.Fprintf(os.Stderr, "rebuilt: %x\n", h)
+	}
+	for _, h := range res.Missing {
+		fmt.Fprintf(os.Stderr, "missing: %x\n", h)
+	}
+	for _, f := range res.Failed {
+		if f.Id == nil {
+			fmt.Fprintf(os.Stderr, "failed: %x: %v\n", f.Hash, f.Err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "failed: %x to %v: %v\n", f.Hash, f.Id, f.Err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/Roman2K/scat/stores/rebalance"
+	"github.com/Roman2K/scat/tmpdedup"
+)
+
+func repairMode(args []string) (err error) {
+	fl := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	var (
+		dryRun    = fl.Bool("n", false, "dry run: only report what would be done")
+		group     = fl.Int("group", 1, "index entries per stripe, as in group(n)")
+		parityStr = fl.String("parity", "", "rebuild lost shards: "+parityDesc)
+		unprocStr = fl.String("unproc", "",
+			"proc string undoing the procs after checksum, ex: \"uencrypt key\"",
+		)
+		procStr = fl.String("proc", "",
+			"proc string redoing them on rebuilt shards, ex: \"encrypt key\"",
+		)
+	)
+	parseModeArgs(fl, args[1:], modeUsage{
+		args: "<stripe> <index>...",
+		descs: [][2]string{
+			{"<stripe>", stripeDesc},
+			{"<index>", "index file, - for stdin"},
+		},
+	}, 2)
+
+	var parity *rebalance.Parity
+	if (*unprocStr == "") != (*procStr == "") {
+		err = errors.New("-unproc and -proc go together")
+		return
+	}
+	if *parityStr != "" {
+		parity = &rebalance.Parity{}
+		parity.Data, parity.Parity, err = parseParity(*parityStr)
+		if err != nil {
+			return
+		}
+		if *group == 1 {
+			*group = parity.Data + parity.Parity
+		}
+	}
+
+	tmp, err := tmpdedup.TempDir("")
+	if err != nil {
+		return
+	}
+	defer tmp.Finish()
+
+	spec, err := parseStripeSpec(tmp, fl.Arg(0))
+	if err != nil {
+		return
+	}
+	groups, err := readIndexGroups(fl.Args()[1:], *group)
+	if err != nil {
+		return
+	}
+	rb := rebalance.Rebalance{
+		Stripe: spec.Config,
+		Qman:   spec.Qman,
+		Parity: parity,
+		DryRun: *dryRun,
+	}
+	if *unprocStr != "" {
+		rb.Unproc, err = parseProc(tmp, *unprocStr)
+		if err != nil {
+			return
+		}
+		defer rb.Unproc.Finish()
+		rb.Proc, err = parseProc(tmp, *procStr)
+		if err != nil {
+			return
+		}
+		defer rb.Proc.Finish()
+	}
+	res, err := rb.Run(groups)
+	printRebalanceResult(res)
+	if err == nil && len(res.Missing) > 0 {
+		err = fmt.Errorf("%d chunks missing from all stores", len(res.Missing))
+	} else if err == nil && len(res.Failed) > 0 {
+		err = fmt.Errorf("%d chunks failed to copy", len(res.Failed))
+	}
+	return
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Roman2K/scat/argproc"
+)
+
+func restoreMode(args []string) (err error) {
+	fl := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	var (
+		dryRun    = fl.Bool("n", false, "only print the restore proc string")
+		withStats = fl.Bool("stats", false, "print stats: rates, quotas, etc.")
+		out       = fl.String("out", "-", "restored output file, - for stdout")
+		inverses  = inversesFlag{}
+	)
+	fl.Var(inverses, "inverse",
+		"`<name>=<proc>` inverse of procs named <name>, ex: cmd=\"cmd gpg -d\"",
+	)
+	parseModeArgs(fl, args[1:], modeUsage{
+		args: "<proc>",
+		descs: [][2]string{
+			{"<proc>", "backup proc string to derive the restore one from"},
+			{"", "reads the index from stdin"},
+		},
+	}, 1)
+
+	procStr, err := argproc.Restore(fl.Arg(0), inverses, *out)
+	if err != nil {
+		return
+	}
+	if *dryRun {
+		fmt.Fprintln(os.Stdout, procStr)
+		return
+	}
+	return runProc(procStr, *withStats, nil)
+}
+
+type inversesFlag map[string]string
+
+func (f inversesFlag) String() string {
+	strs := make([]string, 0, len(f))
+	for k, v := range f {
+		strs = append(strs, k+"="+v)
+	}
+	return strings.Join(strs, " ")
+}
+
+func (f inversesFlag) Set(str string) error {
+	i := strings.IndexByte(str, '=')
+	if i == -1 {
+		return fmt.Errorf("invalid inverse %q, expected <name>=<proc>", str)
+	}
+	f[str[:i]] = str[i+1:]
+	return nil
+}
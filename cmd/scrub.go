@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/index"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/stores/scrub"
+	"github.com/Roman2K/scat/tmpdedup"
+	humanize "github.com/dustin/go-humanize"
+)
+
+const scrubBadExitCode = 3
+
+func scrubMode(args []string) (err error) {
+	fl := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	var (
+		sample = fl.Float64("sample", 100,
+			"percentage of objects to verify, 100 for all",
+		)
+		rateStr    = fl.String("rate", "0", "max bytes read per second and store")
+		quarantine = fl.Bool("quarantine", false, "move bad objects out of stores")
+		maxCorrupt = fl.Float64("max-corrupt", 5,
+			"percentage of corrupt objects past which not to quarantine",
+		)
+		unprocStr = fl.String("unproc", "",
+			"proc string undoing the procs after checksum, ex: \"uencrypt key\"",
+		)
+		indexPath = fl.String("index", "", "only verify chunks of this index")
+	)
+	parseModeArgs(fl, args[1:], modeUsage{
+		args: "<stores>",
+		descs: [][2]string{
+			{"<stores>", storesDesc},
+			{"", fmt.Sprintf("exits with %d if bad objects were found",
+				scrubBadExitCode,
+			)},
+		},
+	}, 1)
+
+	rate, err := humanize.ParseBytes(*rateStr)
+	if err != nil {
+		return
+	}
+	var hashes []checksum.Hash
+	if *indexPath != "" {
+		hashes, err = readIndexHashes(*indexPath)
+		if err != nil {
+			return
+		}
+	}
+
+	tmp, err := tmpdedup.TempDir("")
+	if err != nil {
+		return
+	}
+	defer tmp.Finish()
+
+	ss, err := parseStores(tmp, fl.Arg(0))
+	if err != nil {
+		return
+	}
+	var unproc procs.Proc
+	if *unprocStr != "" {
+		unproc, err = parseProc(tmp, *unprocStr)
+		if err != nil {
+			return
+		}
+		defer unproc.Finish()
+	}
+	sc := scrub.Scrub{
+		Sample:     *sample,
+		Rate:       int64(rate),
+		Unproc:     unproc,
+		Quarantine: *quarantine,
+		MaxCorrupt: *maxCorrupt,
+	}
+	reports, err := sc.Run(ss, hashes)
+	nbad := 0
+	for _, rep := range reports {
+		for _, bad := range rep.Bad {
+			suffix := ""
+			if bad.Err != nil {
+				suffix = fmt.Sprintf(" (%v)", bad.Err)
+			}
+			if bad.Quarantined {
+				suffix = " (quarantined)"
+			}
+			fmt.Fprintf(os.Stdout, "%s: %v %x%s\n",
+				bad.Problem, rep.Id, bad.Hash, suffix,
+			)
+		}
+		nbad += len(rep.Bad)
+	}
+	for _, rep := range reports {
+		fmt.Fprintf(os.Stdout, "%15s\t%8d chunks\t%10s checked\t%d bad\n",
+			rep.Id, rep.Checked, humanize.IBytes(uint64(rep.CheckedSize)),
+			len(rep.Bad),
+		)
+	}
+	if err == nil && nbad > 0 {
+		err = exitCodeErr{fmt.Errorf("scrub: %d bad objects", nbad),
+			scrubBadExitCode,
+		}
+	}
+	return
+}
+
+func readIndexHashes(path string) (hashes []checksum.Hash, err error) {
+	r, err := openIn(path)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	return index.ReadHashes(r)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/Roman2K/scat/stores"
+)
+
+func serveMode(args []string) (err error) {
+	fl := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	var (
+		listen = fl.String("listen", "", "serve over HTTPS on this address "+
+			"rather than stdin/stdout")
+		cert     = fl.String("cert", "", "TLS certificate file, with -listen")
+		key      = fl.String("key", "", "TLS private key file, with -listen")
+		insecure = fl.Bool("insecure", false,
+			"serve over plain HTTP without -cert and -key, ex: behind a proxy",
+		)
+	)
+	parseModeArgs(fl, args[1:], modeUsage{
+		args: "<dir> [<nesting>...]",
+		descs: [][2]string{
+			{"<dir>", "directory to serve, as in cp(<dir> <nesting>...)"},
+			{"", "over HTTPS, requires SCAT_HTTP_USER and SCAT_HTTP_PASSWORD"},
+		},
+	}, 1)
+
+	part := make(stores.StrPart, fl.NArg()-1)
+	for i, arg := range fl.Args()[1:] {
+		part[i], err = strconv.Atoi(arg)
+		if err != nil {
+			return
+		}
+	}
+	srv := stores.Server{Cp: stores.Cp{Path: fl.Arg(0), Part: part}}
+	if *listen == "" {
+		return stores.ServeConn(srv, stores.NewStdioConn())
+	}
+	srv.User = os.Getenv("SCAT_HTTP_USER")
+	srv.Pass = os.Getenv("SCAT_HTTP_PASSWORD")
+	if srv.User == "" || srv.Pass == "" {
+		return errors.New("SCAT_HTTP_USER and SCAT_HTTP_PASSWORD required")
+	}
+	if *cert == "" || *key == "" {
+		if !*insecure {
+			return errors.New("-cert and -key required, or -insecure")
+		}
+		return http.ListenAndServe(*listen, srv)
+	}
+	return http.ListenAndServeTLS(*listen, *cert, *key, srv)
+}
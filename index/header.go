@@ -0,0 +1,98 @@
+package index
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Roman2K/scat/checksum"
+)
+
+const (
+	Version      = 3
+	headerPrefix = "#"
+)
+
+// Metadata written as lines prefixed with headerPrefix, before and after
+// entries. Zero fields are omitted.
+type Header struct {
+	Version  int
+	Time     time.Time
+	Size     int64
+	Sum      checksum.Hash // SHA-256 of the whole stream
+	SplitMin uint
+	SplitMax uint
+	Proc     string
+}
+
+func WriteHeader(w io.Writer, h Header) (err error) {
+	line := func(key, format string, a ...interface{}) {
+		if err != nil {
+			return
+		}
+		_, err = fmt.Fprintf(w, headerPrefix+"%s "+format+"\n",
+			append([]interface{}{key}, a...)...,
+		)
+	}
+	if h.Version != 0 {
+		line("version", "%d", h.Version)
+	}
+	if !h.Time.IsZero() {
+		line("time", "%s", h.Time.UTC().Format(time.RFC3339))
+	}
+	if h.SplitMin != 0 || h.SplitMax != 0 {
+		line("split", "%d %d", h.SplitMin, h.SplitMax)
+	}
+	if h.Proc != "" {
+		line("proc", "%q", h.Proc)
+	}
+	if h.Sum != (checksum.Hash{}) {
+		line("size", "%d", h.Size)
+		line("sha256", "%x", h.Sum)
+	}
+	return
+}
+
+// Unknown keys are ignored for forward compatibility.
+func (h *Header) parseLine(line string) (err error) {
+	line = strings.TrimPrefix(line, headerPrefix)
+	key, val := line, ""
+	if i := strings.IndexByte(line, ' '); i != -1 {
+		key, val = line[:i], line[i+1:]
+	}
+	switch key {
+	case "version":
+		h.Version, err = strconv.Atoi(val)
+		if err == nil && h.Version > Version {
+			err = fmt.Errorf("unsupported index version %d", h.Version)
+		}
+	case "time":
+		h.Time, err = time.Parse(time.RFC3339, val)
+	case "split":
+		_, err = fmt.Sscanf(val, "%d %d", &h.SplitMin, &h.SplitMax)
+	case "proc":
+		h.Proc, err = strconv.Unquote(val)
+	case "size":
+		h.Size, err = strconv.ParseInt(val, 10, 64)
+	case "sha256":
+		buf := []byte{}
+		_, err = fmt.Sscanf(val, "%x", &buf)
+		if err == nil {
+			err = h.Sum.LoadSlice(buf)
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid index header %q: %v", key, err)
+	}
+	return
+}
+
+// Reads the whole index for header lines after entries.
+func ReadHeader(r io.Reader) (h Header, err error) {
+	scan := NewScanner(0, r).(*scanner)
+	for scan.Next() {
+	}
+	return scan.header, scan.Err()
+}
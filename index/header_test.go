@@ -0,0 +1,68 @@
+package index_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/index"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestHeader(t *testing.T) {
+	h1 := checksum.SumBytes([]byte("a"))
+	hdr := index.Header{
+		Version:  index.Version,
+		Time:     time.Date(2017, 3, 17, 5, 55, 0, 0, time.UTC),
+		SplitMin: 1,
+		SplitMax: 2,
+		Proc:     "split | backlog 2 {\n  index -\n}",
+	}
+	buf := &bytes.Buffer{}
+	err := index.WriteHeader(buf, hdr)
+	assert.NoError(t, err)
+	assert.Equal(t, ""+
+		"#version 3\n"+
+		"#time 2017-03-17T05:55:00Z\n"+
+		"#split 1 2\n"+
+		"#proc \"split | backlog 2 {\\n  index -\\n}\"\n",
+		buf.String(),
+	)
+	index.Write(buf, h1, 123)
+	trailer := index.Header{Size: 123, Sum: h1}
+	err = index.WriteHeader(buf, trailer)
+	assert.NoError(t, err)
+
+	// entries
+	scan := index.NewScanner(0, bytes.NewReader(buf.Bytes()))
+	assert.True(t, scan.Next())
+	assert.Equal(t, h1, scan.Chunk().Hash())
+	assert.Equal(t, 123, scan.Chunk().TargetSize())
+	assert.False(t, scan.Next())
+	assert.NoError(t, scan.Err())
+
+	// header
+	read, err := index.ReadHeader(buf)
+	assert.NoError(t, err)
+	hdr.Size, hdr.Sum = trailer.Size, trailer.Sum
+	assert.Equal(t, hdr, read)
+}
+
+func TestHeaderInvalid(t *testing.T) {
+	// newer version
+	buf := bytes.NewBufferString(fmt.Sprintf("#version %d\n", index.Version+1))
+	_, err := index.ReadHeader(buf)
+	assert.Error(t, err)
+
+	// invalid value
+	buf = bytes.NewBufferString("#size x\n")
+	_, err = index.ReadHeader(buf)
+	assert.Error(t, err)
+
+	// unknown key
+	buf = bytes.NewBufferString("#foo bar\n")
+	_, err = index.ReadHeader(buf)
+	assert.NoError(t, err)
+}
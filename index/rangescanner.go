@@ -0,0 +1,121 @@
+package index
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	"github.com/Roman2K/scat"
+)
+
+var errNoOffsets = errors.New("index entries lack offsets, required for ranges")
+
+// Reads entries of the chunks overlapping the byte range [start, end) of the
+// stream, end 0 meaning until the end. Entries get renumbered from num.
+type RangeScanner struct {
+	scan       *scanner
+	start, end int64
+	num        int
+	group      []*scat.Chunk
+	groupOff   int64
+	out        []*scat.Chunk
+	offset     int64
+	started    bool
+	done       bool
+	chunk      *scat.Chunk
+	err        error
+}
+
+var _ Scanner = (*RangeScanner)(nil)
+
+func NewRangeScanner(num int, r io.Reader, start, end int64) *RangeScanner {
+	return &RangeScanner{
+		scan:  NewScanner(0, r).(*scanner),
+		start: start,
+		end:   end,
+		num:   num,
+	}
+}
+
+func (s *RangeScanner) Next() bool {
+	for len(s.out) == 0 {
+		if s.done || s.err != nil {
+			return false
+		}
+		s.err = s.read()
+	}
+	c := s.out[0]
+	s.out = s.out[1:]
+	s.chunk = scat.NewChunk(s.num, c.Data())
+	s.chunk.SetHash(c.Hash())
+	s.chunk.SetTargetSize(c.TargetSize())
+	if off, ok := Offset(c); ok {
+		s.chunk.Meta().Set(metaOffset, off)
+	}
+	s.num++
+	return true
+}
+
+func (s *RangeScanner) read() error {
+	if !s.scan.Next() {
+		if err := s.scan.Err(); err != nil {
+			return err
+		}
+		s.done = true
+		end := s.scan.header.Size
+		if end == 0 {
+			// Unknown stream size: the last chunk may overlap
+			end = math.MaxInt64
+		}
+		s.flush(end)
+		return nil
+	}
+	c := s.scan.Chunk()
+	off, ok := Offset(c)
+	if !ok {
+		return errNoOffsets
+	}
+	if len(s.group) > 0 && off != s.groupOff {
+		s.flush(off)
+	}
+	if s.end != 0 && off >= s.end {
+		s.done = true
+		return nil
+	}
+	s.group = append(s.group, c)
+	s.groupOff = off
+	return nil
+}
+
+func (s *RangeScanner) flush(groupEnd int64) {
+	group := s.group
+	s.group = nil
+	if len(group) == 0 || groupEnd <= s.start {
+		return
+	}
+	if s.end != 0 && s.groupOff >= s.end {
+		return
+	}
+	if !s.started {
+		s.started = true
+		s.offset = s.groupOff
+	}
+	s.out = append(s.out, group...)
+}
+
+// Offset in the stream of the first chunk read, once Next returned true.
+func (s *RangeScanner) Offset() int64 {
+	return s.offset
+}
+
+func (s *RangeScanner) Header() Header {
+	return s.scan.Header()
+}
+
+func (s *RangeScanner) Chunk() *scat.Chunk {
+	return s.chunk
+}
+
+func (s *RangeScanner) Err() error {
+	return s.err
+}
@@ -0,0 +1,73 @@
+package index_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/index"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestRangeScanner(t *testing.T) {
+	h1 := checksum.SumBytes([]byte("a"))
+	h2 := checksum.SumBytes([]byte("b"))
+	h3 := checksum.SumBytes([]byte("c"))
+	h4 := checksum.SumBytes([]byte("d"))
+	str := "" +
+		"#version 3\n" +
+		fmt.Sprintf("%x 10 0\n", h1) +
+		fmt.Sprintf("%x 20 10\n", h2) +
+		fmt.Sprintf("%x 20 10\n", h3) +
+		fmt.Sprintf("%x 5 30\n", h4) +
+		"#size 35\n"
+
+	read := func(start, end int64) (hashes []checksum.Hash, nums []int,
+		off int64,
+	) {
+		scan := index.NewRangeScanner(3, bytes.NewBufferString(str), start, end)
+		for scan.Next() {
+			hashes = append(hashes, scan.Chunk().Hash())
+			nums = append(nums, scan.Chunk().Num())
+		}
+		assert.NoError(t, scan.Err())
+		off = scan.Offset()
+		return
+	}
+
+	hashes, nums, off := read(0, 0)
+	assert.Equal(t, []checksum.Hash{h1, h2, h3, h4}, hashes)
+	assert.Equal(t, []int{3, 4, 5, 6}, nums)
+	assert.Equal(t, int64(0), off)
+
+	// entries of a chunk stay together
+	hashes, nums, off = read(15, 16)
+	assert.Equal(t, []checksum.Hash{h2, h3}, hashes)
+	assert.Equal(t, []int{3, 4}, nums)
+	assert.Equal(t, int64(10), off)
+
+	// boundaries
+	hashes, _, off = read(10, 31)
+	assert.Equal(t, []checksum.Hash{h2, h3, h4}, hashes)
+	assert.Equal(t, int64(10), off)
+	hashes, _, _ = read(0, 10)
+	assert.Equal(t, []checksum.Hash{h1}, hashes)
+
+	// last chunk, until the end
+	hashes, _, off = read(34, 0)
+	assert.Equal(t, []checksum.Hash{h4}, hashes)
+	assert.Equal(t, int64(30), off)
+
+	// past the end
+	hashes, _, _ = read(35, 0)
+	assert.Equal(t, 0, len(hashes))
+}
+
+func TestRangeScannerNoOffsets(t *testing.T) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%x 123\n", checksum.SumBytes([]byte("a")))
+	scan := index.NewRangeScanner(0, buf, 0, 1)
+	assert.False(t, scan.Next())
+	assert.Error(t, scan.Err())
+}
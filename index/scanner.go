@@ -1,29 +1,53 @@
 package index
 
 import (
-	"fmt"
+	"bufio"
+	"encoding/hex"
+	"errors"
 	"io"
+	"strconv"
+	"strings"
 
 	"github.com/Roman2K/scat"
 	"github.com/Roman2K/scat/checksum"
 )
 
+var errInvalidLine = errors.New("invalid index line")
+
+type metaKey int
+
+const metaOffset metaKey = iota
+
 type scanner struct {
-	r       io.Reader
-	hashBuf []byte
-	num     int
-	chunk   *scat.Chunk
-	err     error
+	r      *bufio.Reader
+	num    int
+	chunk  *scat.Chunk
+	header Header
+	err    error
+}
+
+type Scanner interface {
+	scat.ChunkIter
+
+	// Header lines read so far, all of them once Next returned false
+	Header() Header
 }
 
-func NewScanner(num int, r io.Reader) scat.ChunkIter {
+// Reads entries, with or without header lines and offsets.
+func NewScanner(num int, r io.Reader) Scanner {
 	return &scanner{
-		r:       r,
-		hashBuf: make([]byte, len(checksum.Hash{})),
-		num:     num,
+		r:   bufio.NewReader(r),
+		num: num,
 	}
 }
 
+// Offset in the stream of the chunk a scanned entry belongs to, if the index
+// records it.
+func Offset(c *scat.Chunk) (off int64, ok bool) {
+	off, ok = c.Meta().Get(metaOffset).(int64)
+	return
+}
+
 func (s *scanner) Next() bool {
 	err := s.scan()
 	if err != nil {
@@ -37,27 +61,80 @@ func (s *scanner) Next() bool {
 }
 
 func (s *scanner) scan() (err error) {
-	var size int
-	n, err := fmt.Fscanf(s.r, "%x %d\n", &s.hashBuf, &size)
+	err = s.scanHeader()
 	if err != nil {
 		return
 	}
-	if n != 2 {
-		return fmt.Errorf("invalid index line")
+	line, err := s.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
 	}
-	chunk := scat.NewChunk(s.num, nil)
-	chunk.SetTargetSize(size)
-	hash := checksum.Hash{}
-	err = hash.LoadSlice(s.hashBuf)
 	if err != nil {
 		return
 	}
-	chunk.SetHash(hash)
+	chunk, err := parseEntry(s.num, line)
+	if err != nil {
+		return
+	}
 	s.chunk = chunk
 	s.num++
 	return
 }
 
+func parseEntry(num int, line string) (chunk *scat.Chunk, err error) {
+	fields := strings.Fields(line)
+	if n := len(fields); n != 2 && n != 3 {
+		err = errInvalidLine
+		return
+	}
+	b, err := hex.DecodeString(fields[0])
+	if err != nil {
+		return
+	}
+	hash := checksum.Hash{}
+	err = hash.LoadSlice(b)
+	if err != nil {
+		return
+	}
+	size, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return
+	}
+	chunk = scat.NewChunk(num, nil)
+	chunk.SetHash(hash)
+	chunk.SetTargetSize(size)
+	if len(fields) == 3 {
+		var off int64
+		off, err = strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return
+		}
+		chunk.Meta().Set(metaOffset, off)
+	}
+	return
+}
+
+func (s *scanner) scanHeader() error {
+	for {
+		b, err := s.r.Peek(len(headerPrefix))
+		if err != nil || string(b) != headerPrefix {
+			return err
+		}
+		line, err := s.r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		err = s.header.parseLine(strings.TrimSuffix(line, "\n"))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *scanner) Header() Header {
+	return s.header
+}
+
 func (s *scanner) Chunk() *scat.Chunk {
 	return s.chunk
 }
@@ -65,3 +142,12 @@ func (s *scanner) Chunk() *scat.Chunk {
 func (s *scanner) Err() error {
 	return s.err
 }
+
+func ReadHashes(r io.Reader) (hashes []checksum.Hash, err error) {
+	scan := NewScanner(0, r)
+	for scan.Next() {
+		hashes = append(hashes, scan.Chunk().Hash())
+	}
+	err = scan.Err()
+	return
+}
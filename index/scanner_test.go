@@ -41,3 +41,44 @@ func TestScanner(t *testing.T) {
 	assert.False(t, scan.Next())
 	assert.NoError(t, scan.Err())
 }
+
+func TestScannerOffsets(t *testing.T) {
+	buf := &bytes.Buffer{}
+	scan := index.NewScanner(0, buf)
+
+	h1 := checksum.SumBytes([]byte("a"))
+	fmt.Fprintf(buf, "%x 123 456\n", h1)
+	fmt.Fprintf(buf, "%x 123\n", h1)
+
+	assert.True(t, scan.Next())
+	assert.Equal(t, h1, scan.Chunk().Hash())
+	assert.Equal(t, 123, scan.Chunk().TargetSize())
+	off, ok := index.Offset(scan.Chunk())
+	assert.True(t, ok)
+	assert.Equal(t, int64(456), off)
+
+	assert.True(t, scan.Next())
+	_, ok = index.Offset(scan.Chunk())
+	assert.False(t, ok)
+
+	assert.False(t, scan.Next())
+	assert.NoError(t, scan.Err())
+
+	// invalid
+	buf.WriteString("xyz 1\n")
+	scan = index.NewScanner(0, buf)
+	assert.False(t, scan.Next())
+	assert.Error(t, scan.Err())
+}
+
+func TestReadHashes(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h1 := checksum.SumBytes([]byte("a"))
+	h2 := checksum.SumBytes([]byte("b"))
+	fmt.Fprintf(buf, "%x 123\n", h1)
+	fmt.Fprintf(buf, "%x 456\n", h2)
+
+	hashes, err := index.ReadHashes(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []checksum.Hash{h1, h2}, hashes)
+}
@@ -10,3 +10,16 @@ import (
 func Write(w io.Writer, hash checksum.Hash, size int) (int, error) {
 	return fmt.Fprintf(w, "%x %d\n", hash, size)
 }
+
+// Also writes the offset in the stream of the chunk the entry belongs to,
+// shared by all the entries of that chunk.
+func WriteOffset(w io.Writer, hash checksum.Hash, size int, offset int64) (
+	int, error,
+) {
+	return fmt.Fprintf(w, "%x %d %d\n", hash, size, offset)
+}
+
+// Chunk of zeros at offset, stored nowhere.
+func WriteHole(w io.Writer, offset int64, size int) (int, error) {
+	return fmt.Fprintf(w, headerPrefix+"hole %d %d\n", offset, size)
+}
@@ -82,7 +82,7 @@ func TestRecoveryRaid5(t *testing.T) {
 		return indexBuf.Bytes()
 	}
 	read := func(index []byte) hash.Hash {
-		mrd, err := stores.NewMultiReader(readers)
+		mrd, err := stores.NewMultiReader(readers, nil)
 		assert.NoError(t, err)
 		hashOut := sha256.New()
 		proc := procs.Chain{
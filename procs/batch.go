@@ -0,0 +1,86 @@
+package procs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Roman2K/scat"
+)
+
+type BatchFn func([]*scat.Chunk) error
+
+type batch struct {
+	fn    BatchFn
+	size  int
+	delay time.Duration
+	cur   *pendingBatch
+	mu    sync.Mutex
+}
+
+type pendingBatch struct {
+	chunks []*scat.Chunk
+	timer  *time.Timer
+	done   chan struct{}
+	err    error
+}
+
+// Hands chunks processed concurrently to fn in batches of up to size chunks.
+// Batches are cut short once no chunk has been added for delay, as the
+// concurrency upstream may not allow more. Each chunk's result, fn's error,
+// is only sent once its batch is done.
+func NewBatch(size int, delay time.Duration, fn BatchFn) Proc {
+	const min = 1
+	if size < min {
+		panic(fmt.Errorf("size must be >= %d", min))
+	}
+	return &batch{fn: fn, size: size, delay: delay}
+}
+
+func (b *batch) Process(c *scat.Chunk) <-chan Res {
+	b.mu.Lock()
+	pb := b.cur
+	if pb == nil {
+		pb = &pendingBatch{done: make(chan struct{})}
+		pb.timer = time.AfterFunc(b.delay, func() { b.flush(pb) })
+		b.cur = pb
+	}
+	pb.chunks = append(pb.chunks, c)
+	if len(pb.chunks) >= b.size {
+		go b.flush(pb)
+	} else {
+		pb.timer.Reset(b.delay)
+	}
+	b.mu.Unlock()
+
+	ch := make(chan Res)
+	go func() {
+		defer close(ch)
+		<-pb.done
+		ch <- Res{Chunk: c, Err: pb.err}
+	}()
+	return ch
+}
+
+func (b *batch) flush(pb *pendingBatch) {
+	b.mu.Lock()
+	if b.cur != pb {
+		b.mu.Unlock()
+		return
+	}
+	b.cur = nil
+	b.mu.Unlock()
+	pb.timer.Stop()
+	pb.err = b.fn(pb.chunks)
+	close(pb.done)
+}
+
+func (b *batch) Finish() error {
+	b.mu.Lock()
+	pb := b.cur
+	b.mu.Unlock()
+	if pb != nil {
+		b.flush(pb)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package procs_test
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/testutil"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	var (
+		batches [][]int
+		mu      sync.Mutex
+		someErr = errors.New("some err")
+	)
+	proc := procs.NewBatch(2, 10*time.Millisecond,
+		func(chunks []*scat.Chunk) error {
+			nums := make([]int, len(chunks))
+			for i, c := range chunks {
+				nums[i] = c.Num()
+			}
+			sort.Ints(nums)
+			mu.Lock()
+			defer mu.Unlock()
+			batches = append(batches, nums)
+			if nums[0] == 4 {
+				return someErr
+			}
+			return nil
+		},
+	)
+	process := func(nums ...int) (errs []error) {
+		errs = make([]error, len(nums))
+		wg := sync.WaitGroup{}
+		wg.Add(len(nums))
+		for i, n := range nums {
+			i, c := i, scat.NewChunk(n, nil)
+			go func() {
+				defer wg.Done()
+				chunks, err := testutil.ReadChunks(proc.Process(c))
+				assert.Equal(t, []*scat.Chunk{c}, chunks)
+				errs[i] = err
+			}()
+		}
+		wg.Wait()
+		return
+	}
+
+	// full
+	errs := process(0, 1)
+	assert.Equal(t, []error{nil, nil}, errs)
+	assert.Equal(t, [][]int{{0, 1}}, batches)
+
+	// cut short after delay
+	errs = process(2)
+	assert.Equal(t, []error{nil}, errs)
+	assert.Equal(t, [][]int{{0, 1}, {2}}, batches)
+
+	// errors sent for each chunk of the batch
+	errs = process(4, 5)
+	assert.Equal(t, []error{someErr, someErr}, errs)
+	assert.Equal(t, [][]int{{0, 1}, {2}, {4, 5}}, batches)
+
+	assert.NoError(t, proc.Finish())
+}
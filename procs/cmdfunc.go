@@ -43,7 +43,7 @@ func (fn CmdInFunc) process(c *scat.Chunk) (err error) {
 		return
 	}
 	cmd.Stdin = c.Data().Reader()
-	return runCaptureStderr(cmd)
+	return runCaptureStderr(cmd, Done(c))
 }
 
 func (CmdInFunc) Finish() error {
@@ -63,7 +63,7 @@ func (fn CmdOutFunc) process(c *scat.Chunk) (new *scat.Chunk, err error) {
 	}
 	buf := &bytes.Buffer{}
 	cmd.Stdout = buf
-	err = runCaptureStderr(cmd)
+	err = runCaptureStderr(cmd, Done(c))
 	new = c.WithData(scat.BytesData(buf.Bytes()))
 	return
 }
@@ -72,13 +72,32 @@ func (CmdOutFunc) Finish() error {
 	return nil
 }
 
-func runCaptureStderr(cmd *exec.Cmd) (err error) {
+// The command is killed if done is closed before it exits.
+func runCaptureStderr(cmd *exec.Cmd, done <-chan struct{}) (err error) {
 	var errBuf *bytes.Buffer
 	if cmd.Stderr == nil {
 		errBuf = &bytes.Buffer{}
 		cmd.Stderr = errBuf
 	}
-	err = cmd.Run()
+	err = cmd.Start()
+	if err != nil {
+		return
+	}
+	exited := make(chan struct{})
+	killed := make(chan bool, 1)
+	go func() {
+		select {
+		case <-done:
+			killed <- cmd.Process.Kill() == nil
+		case <-exited:
+			killed <- false
+		}
+	}()
+	err = cmd.Wait()
+	close(exited)
+	if <-killed {
+		return ErrCanceled
+	}
 	if exit, ok := err.(*exec.ExitError); ok {
 		if len(exit.Stderr) == 0 && errBuf != nil {
 			exit.Stderr = errBuf.Bytes()
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os/exec"
 	"testing"
+	"time"
 
 	assert "github.com/stretchr/testify/require"
 	"github.com/Roman2K/scat"
@@ -110,3 +111,16 @@ func testCmdFuncError(t *testing.T, getProc func(procs.CmdFunc) procs.Proc) {
 	assert.Equal(t, []*scat.Chunk{c}, chunks)
 	assert.Equal(t, errOut, string(exit.Stderr))
 }
+
+func TestCmdOutFuncCanceled(t *testing.T) {
+	cmdp := procs.CmdOutFunc(func(*scat.Chunk) (*exec.Cmd, error) {
+		return exec.Command("sleep", "10"), nil
+	})
+	done := make(chan struct{})
+	c := procs.WithDone(scat.NewChunk(0, nil), done)
+	close(done)
+	start := time.Now()
+	_, err := testutil.ReadChunks(cmdp.Process(c))
+	assert.Equal(t, procs.ErrCanceled, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
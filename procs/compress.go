@@ -0,0 +1,117 @@
+package procs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/Roman2K/scat"
+)
+
+var (
+	compressMagic         = []byte("SCZ")
+	errUnknownCompression = errors.New("unknown compression format")
+)
+
+type chunkCodec interface {
+	process(*scat.Chunk) (*scat.Chunk, error)
+	unprocess(*scat.Chunk) (*scat.Chunk, error)
+}
+
+type compressCodec struct {
+	name  string
+	magic []byte
+	new   func(level int) chunkCodec
+}
+
+// Header ids are indexes: append only.
+var compressCodecs = []compressCodec{
+	{"stored", nil, nil},
+	{"gzip", []byte{0x1f, 0x8b},
+		func(l int) chunkCodec { return Gzip{l} }},
+	{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd},
+		func(l int) chunkCodec { return Zstd{l} }},
+	{"lz4", []byte{0x04, 0x22, 0x4d, 0x18},
+		func(l int) chunkCodec { return Lz4{l} }},
+	{"xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00},
+		func(l int) chunkCodec { return Xz{l} }},
+}
+
+type compress struct {
+	id    byte
+	codec chunkCodec
+}
+
+// Prefixes output with a header identifying the codec, or "stored" when
+// compression didn't reduce size. Unproc reads any codec from the header, and
+// headerless output of the plain codec procs from their magic number.
+func NewCompress(codec string, level int) (p ProcUnprocer, err error) {
+	for i, cc := range compressCodecs {
+		if cc.name == codec && cc.new != nil {
+			p = compress{id: byte(i), codec: cc.new(level)}
+			return
+		}
+	}
+	err = fmt.Errorf("unknown codec %q", codec)
+	return
+}
+
+func (cp compress) Proc() Proc {
+	return ChunkFunc(cp.process)
+}
+
+func (compress) Unproc() Proc {
+	return Ucompress
+}
+
+func (cp compress) process(c *scat.Chunk) (new *scat.Chunk, err error) {
+	plain, err := c.Data().Bytes()
+	if err != nil {
+		return
+	}
+	comp, err := cp.codec.process(c)
+	if err != nil {
+		return
+	}
+	payload, err := comp.Data().Bytes()
+	if err != nil {
+		return
+	}
+	id := cp.id
+	if len(payload) >= len(plain) {
+		id, payload = 0, plain
+	}
+	buf := make([]byte, 0, len(compressMagic)+1+len(payload))
+	buf = append(append(append(buf, compressMagic...), id), payload...)
+	new = c.WithData(scat.BytesData(buf))
+	return
+}
+
+var Ucompress Proc = ChunkFunc(ucompress)
+
+func ucompress(c *scat.Chunk) (new *scat.Chunk, err error) {
+	b, err := c.Data().Bytes()
+	if err != nil {
+		return
+	}
+	hlen := len(compressMagic) + 1
+	if len(b) >= hlen && bytes.HasPrefix(b, compressMagic) {
+		id := int(b[hlen-1])
+		if id >= len(compressCodecs) {
+			err = fmt.Errorf("unknown codec id %d", id)
+			return
+		}
+		new = c.WithData(scat.BytesData(b[hlen:]))
+		if cc := compressCodecs[id]; cc.new != nil {
+			new, err = cc.new(0).unprocess(new)
+		}
+		return
+	}
+	for _, cc := range compressCodecs {
+		if cc.magic != nil && bytes.HasPrefix(b, cc.magic) {
+			return cc.new(0).unprocess(c)
+		}
+	}
+	err = errUnknownCompression
+	return
+}
@@ -0,0 +1,118 @@
+package procs_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/testutil"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestCompress(t *testing.T) {
+	data := bytes.Repeat([]byte("abc"), 1000)
+
+	roundTrip := func(pu procs.ProcUnprocer) {
+		proc, unproc := pu.Proc(), pu.Unproc()
+		for i := 0; i < 2; i++ {
+			c := scat.NewChunk(i, scat.BytesData(data))
+			chunks, err := testutil.ReadChunks(proc.Process(c))
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(chunks))
+			b, err := chunks[0].Data().Bytes()
+			assert.NoError(t, err)
+			assert.True(t, len(b) < len(data))
+			chunks, err = testutil.ReadChunks(unproc.Process(chunks[0]))
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(chunks))
+			b, err = chunks[0].Data().Bytes()
+			assert.NoError(t, err)
+			assert.Equal(t, data, b)
+		}
+		assert.NoError(t, proc.Finish())
+		assert.NoError(t, unproc.Finish())
+	}
+
+	for _, level := range []int{0, 1, 9} {
+		roundTrip(procs.Gzip{Level: level})
+		roundTrip(procs.Zstd{Level: level})
+		roundTrip(procs.Lz4{Level: level})
+		roundTrip(procs.Xz{Level: level})
+	}
+	roundTrip(procs.Zstd{Level: 22})
+
+	// invalid level
+	for _, pu := range []procs.ProcUnprocer{
+		procs.Gzip{Level: 10},
+		procs.Xz{Level: 10},
+	} {
+		c := scat.NewChunk(0, scat.BytesData(data))
+		_, err := testutil.ReadChunks(pu.Proc().Process(c))
+		assert.Error(t, err)
+	}
+}
+
+func TestCompressHeader(t *testing.T) {
+	var (
+		compressible   = bytes.Repeat([]byte("abc"), 1000)
+		incompressible = []byte("abc")
+	)
+
+	_, err := procs.NewCompress("foo", 0)
+	assert.Error(t, err)
+	_, err = procs.NewCompress("stored", 0)
+	assert.Error(t, err)
+
+	run := func(proc procs.Proc, data []byte) ([]byte, error) {
+		c := scat.NewChunk(0, scat.BytesData(data))
+		chunks, err := testutil.ReadChunks(proc.Process(c))
+		if err != nil {
+			return nil, err
+		}
+		assert.Equal(t, 1, len(chunks))
+		return chunks[0].Data().Bytes()
+	}
+	for _, codec := range []string{"gzip", "zstd", "lz4", "xz"} {
+		comp, err := procs.NewCompress(codec, 0)
+		assert.NoError(t, err)
+
+		// compressed
+		b, err := run(comp.Proc(), compressible)
+		assert.NoError(t, err)
+		assert.Equal(t, "SCZ", string(b[:3]))
+		assert.NotEqual(t, byte(0), b[3])
+		assert.True(t, len(b) < len(compressible))
+		b, err = run(comp.Unproc(), b)
+		assert.NoError(t, err)
+		assert.Equal(t, compressible, b)
+
+		// stored
+		b, err = run(comp.Proc(), incompressible)
+		assert.NoError(t, err)
+		assert.Equal(t, "SCZ\x00abc", string(b))
+		b, err = run(comp.Unproc(), b)
+		assert.NoError(t, err)
+		assert.Equal(t, incompressible, b)
+	}
+
+	ucomp, err := procs.NewCompress("zstd", 0)
+	assert.NoError(t, err)
+
+	// headerless
+	for _, pu := range []procs.ProcUnprocer{
+		procs.Gzip{}, procs.Zstd{}, procs.Lz4{}, procs.Xz{},
+	} {
+		b, err := run(pu.Proc(), compressible)
+		assert.NoError(t, err)
+		b, err = run(ucomp.Unproc(), b)
+		assert.NoError(t, err)
+		assert.Equal(t, compressible, b)
+	}
+
+	// unknown
+	_, err = run(ucomp.Unproc(), []byte("abc"))
+	assert.Error(t, err)
+	_, err = run(ucomp.Unproc(), []byte("SCZ\xffabc"))
+	assert.Error(t, err)
+}
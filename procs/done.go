@@ -0,0 +1,36 @@
+package procs
+
+import (
+	"errors"
+
+	"github.com/Roman2K/scat"
+)
+
+// Returned by procs having stopped processing a chunk on its done channel
+// being closed: see WithDone.
+var ErrCanceled = errors.New("processing canceled")
+
+// Copy of c with done attached, for procs able to stop processing it early
+// to do so once done is closed. Chunks derived from the copy carry it along.
+func WithDone(c *scat.Chunk, done <-chan struct{}) *scat.Chunk {
+	dup := c.WithData(c.Data())
+	dup.Meta().Set(metaDone, done)
+	return dup
+}
+
+// Nil for chunks that may not be abandoned.
+func Done(c *scat.Chunk) <-chan struct{} {
+	done, _ := c.Meta().Get(metaDone).(<-chan struct{})
+	return done
+}
+
+// Whether the done channel of c is closed, for procs unable to stop midway to
+// check before starting.
+func Canceled(c *scat.Chunk) bool {
+	select {
+	case <-Done(c):
+		return true
+	default:
+		return false
+	}
+}
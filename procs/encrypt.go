@@ -0,0 +1,108 @@
+package procs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Roman2K/scat"
+)
+
+const EncryptMinKeySize = 32
+
+var errCiphertextTooShort = errors.New("ciphertext too short")
+
+type encrypt struct {
+	aead       cipher.AEAD
+	nonceKey   []byte
+	convergent bool
+}
+
+// AES-256-GCM. Output is the nonce followed by the sealed data. In convergent
+// mode, the nonce is a keyed hash of the plaintext so that identical chunks
+// produce identical ciphertext.
+func NewEncrypt(key []byte, convergent bool) (p ProcUnprocer, err error) {
+	if len(key) < EncryptMinKeySize {
+		err = fmt.Errorf("key must be at least %d bytes", EncryptMinKeySize)
+		return
+	}
+	block, err := aes.NewCipher(deriveKey(key, "scat encrypt"))
+	if err != nil {
+		return
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return
+	}
+	p = &encrypt{
+		aead:       aead,
+		nonceKey:   deriveKey(key, "scat nonce"),
+		convergent: convergent,
+	}
+	return
+}
+
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+func (e *encrypt) Proc() Proc {
+	return ChunkFunc(e.process)
+}
+
+func (e *encrypt) Unproc() Proc {
+	return ChunkFunc(e.unprocess)
+}
+
+func (e *encrypt) process(c *scat.Chunk) (new *scat.Chunk, err error) {
+	plain, err := c.Data().Bytes()
+	if err != nil {
+		return
+	}
+	nonce, err := e.nonce(plain)
+	if err != nil {
+		return
+	}
+	sealed := e.aead.Seal(nonce, nonce, plain, nil)
+	new = c.WithData(scat.BytesData(sealed))
+	return
+}
+
+func (e *encrypt) nonce(plain []byte) (nonce []byte, err error) {
+	size := e.aead.NonceSize()
+	if e.convergent {
+		mac := hmac.New(sha256.New, e.nonceKey)
+		mac.Write(plain)
+		nonce = mac.Sum(nil)[:size]
+		return
+	}
+	nonce = make([]byte, size)
+	_, err = io.ReadFull(rand.Reader, nonce)
+	return
+}
+
+func (e *encrypt) unprocess(c *scat.Chunk) (new *scat.Chunk, err error) {
+	sealed, err := c.Data().Bytes()
+	if err != nil {
+		return
+	}
+	size := e.aead.NonceSize()
+	if len(sealed) < size {
+		err = errCiphertextTooShort
+		return
+	}
+	plain, err := e.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		err = ErrIntegrityCheckFailed
+		return
+	}
+	new = c.WithData(scat.BytesData(plain))
+	return
+}
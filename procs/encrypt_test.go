@@ -0,0 +1,68 @@
+package procs_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/testutil"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestEncrypt(t *testing.T) {
+	var (
+		key  = bytes.Repeat([]byte("k"), procs.EncryptMinKeySize)
+		data = "abc"
+	)
+
+	_, err := procs.NewEncrypt(key[1:], false)
+	assert.Error(t, err)
+
+	seal := func(convergent bool) []byte {
+		enc, err := procs.NewEncrypt(key, convergent)
+		assert.NoError(t, err)
+		c := scat.NewChunk(0, scat.BytesData(data))
+		chunks, err := testutil.ReadChunks(enc.Proc().Process(c))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(chunks))
+		b, err := chunks[0].Data().Bytes()
+		assert.NoError(t, err)
+		assert.NotContains(t, string(b), data)
+		return b
+	}
+	open := func(sealed []byte) (string, error) {
+		enc, err := procs.NewEncrypt(key, false)
+		assert.NoError(t, err)
+		c := scat.NewChunk(0, scat.BytesData(sealed))
+		chunks, err := testutil.ReadChunks(enc.Unproc().Process(c))
+		if err != nil {
+			return "", err
+		}
+		b, err := chunks[0].Data().Bytes()
+		return string(b), err
+	}
+
+	// random nonce
+	a, b := seal(false), seal(false)
+	assert.NotEqual(t, a, b)
+	plain, err := open(a)
+	assert.NoError(t, err)
+	assert.Equal(t, data, plain)
+
+	// convergent
+	a, b = seal(true), seal(true)
+	assert.Equal(t, a, b)
+	plain, err = open(a)
+	assert.NoError(t, err)
+	assert.Equal(t, data, plain)
+
+	// tampered
+	a[len(a)-1] ^= 1
+	_, err = open(a)
+	assert.Equal(t, procs.ErrIntegrityCheckFailed, err)
+
+	// truncated
+	_, err = open(a[:3])
+	assert.Error(t, err)
+}
@@ -25,6 +25,8 @@ type metaKey int
 const (
 	metaGroup metaKey = iota
 	metaGroupErr
+	metaHole
+	metaDone
 )
 
 func NewGroup(size int) Group {
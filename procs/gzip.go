@@ -10,7 +10,7 @@ import (
 )
 
 type Gzip struct {
-	// TODO level
+	Level int // 1-9, 0 for default
 }
 
 func (gz Gzip) Proc() Proc {
@@ -21,9 +21,16 @@ func (gz Gzip) Unproc() Proc {
 	return ChunkFunc(gz.unprocess)
 }
 
-func (Gzip) process(c *scat.Chunk) (new *scat.Chunk, err error) {
+func (gz Gzip) process(c *scat.Chunk) (new *scat.Chunk, err error) {
+	level := gz.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
 	buf := &bytes.Buffer{}
-	w := gzip.NewWriter(buf)
+	w, err := gzip.NewWriterLevel(buf, level)
+	if err != nil {
+		return
+	}
 	_, err = io.Copy(w, c.Data().Reader())
 	if err != nil {
 		return
@@ -0,0 +1,115 @@
+package procs
+
+import (
+	"time"
+
+	"github.com/Roman2K/scat"
+)
+
+// Like Cascade, except that the proc being waited on for longer than Delay is
+// raced against the next one. The results of the first to succeed are used,
+// while the other is canceled: the chunk it's given is closed to it, see
+// WithDone, and its results are discarded.
+type Hedged struct {
+	Procs []Proc
+	Delay time.Duration
+}
+
+var _ Proc = Hedged{}
+
+func (h Hedged) Process(c *scat.Chunk) <-chan Res {
+	out := make(chan Res)
+	go func() {
+		defer close(out)
+		if len(h.Procs) == 0 {
+			return
+		}
+		var (
+			cancel  = make(chan struct{})
+			attempt = WithDone(c, mergeDone(Done(c), cancel))
+			done    = make(chan []Res, len(h.Procs))
+			next    = 0
+			running = 0
+			timer   = time.NewTimer(h.Delay)
+			hedge   = timer.C
+			last    []Res
+		)
+		defer timer.Stop()
+		start := func() {
+			proc := h.Procs[next]
+			next++
+			running++
+			go func() {
+				done <- collectRes(proc.Process(attempt))
+			}()
+		}
+		start()
+		for running > 0 {
+			select {
+			case <-hedge:
+				hedge = nil
+				if next < len(h.Procs) {
+					start()
+				}
+			case last = <-done:
+				running--
+				if !hasErr(last) {
+					running = 0
+					break
+				}
+				if running == 0 && next < len(h.Procs) {
+					if !timer.Stop() && hedge != nil {
+						<-timer.C
+					}
+					timer.Reset(h.Delay)
+					hedge = timer.C
+					start()
+				}
+			}
+		}
+		close(cancel)
+		for _, res := range last {
+			if res.Chunk != nil {
+				res.Chunk = WithDone(res.Chunk, Done(c))
+			}
+			out <- res
+		}
+	}()
+	return out
+}
+
+// Closed once either a or b is, a being possibly nil.
+func mergeDone(a, b <-chan struct{}) <-chan struct{} {
+	if a == nil {
+		return b
+	}
+	merged := make(chan struct{})
+	go func() {
+		defer close(merged)
+		select {
+		case <-a:
+		case <-b:
+		}
+	}()
+	return merged
+}
+
+func collectRes(ch <-chan Res) (buf []Res) {
+	for res := range ch {
+		buf = append(buf, res)
+	}
+	return
+}
+
+func hasErr(buf []Res) bool {
+	for _, res := range buf {
+		if res.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (h Hedged) Finish() error {
+	return finishFuncs(h.Procs).FirstErr()
+}
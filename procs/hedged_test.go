@@ -0,0 +1,100 @@
+package procs_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/testutil"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestHedged(t *testing.T) {
+	someErr := errors.New("some err")
+	c := scat.NewChunk(0, nil)
+	newProc := func(data string, err error) (procs.Proc, chan struct{}) {
+		gate := make(chan struct{})
+		proc := procs.ProcFunc(func(c *scat.Chunk) <-chan procs.Res {
+			ch := make(chan procs.Res)
+			go func() {
+				defer close(ch)
+				<-gate
+				ch <- procs.Res{Chunk: c.WithData(scat.BytesData(data)), Err: err}
+			}()
+			return ch
+		})
+		return proc, gate
+	}
+	read := func(h procs.Hedged) (string, error) {
+		chunks, err := testutil.ReadChunks(h.Process(c))
+		if len(chunks) != 1 {
+			return "", err
+		}
+		b, berr := chunks[0].Data().Bytes()
+		assert.NoError(t, berr)
+		return string(b), err
+	}
+
+	// none
+	chunks, err := testutil.ReadChunks(procs.Hedged{}.Process(c))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(chunks))
+
+	// first in time
+	a, agate := newProc("a", nil)
+	b, _ := newProc("b", nil)
+	close(agate)
+	data, err := read(procs.Hedged{[]procs.Proc{a, b}, time.Hour})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", data)
+
+	// late: raced against the next, the loser canceled
+	canceled := make(chan struct{})
+	a = procs.ProcFunc(func(c *scat.Chunk) <-chan procs.Res {
+		ch := make(chan procs.Res)
+		go func() {
+			defer close(ch)
+			<-procs.Done(c)
+			close(canceled)
+			ch <- procs.Res{Chunk: c, Err: procs.ErrCanceled}
+		}()
+		return ch
+	})
+	b, bgate := newProc("b", nil)
+	close(bgate)
+	chunks, err = testutil.ReadChunks(
+		procs.Hedged{[]procs.Proc{a, b}, time.Millisecond}.Process(c),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(chunks))
+	assert.Nil(t, procs.Done(chunks[0]))
+	<-canceled
+
+	// late and failing: the other one waited on
+	a, agate = newProc("a", nil)
+	b, bgate = newProc("b", someErr)
+	close(bgate)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(agate)
+	}()
+	data, err = read(procs.Hedged{[]procs.Proc{a, b}, time.Millisecond})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", data)
+
+	// failing early: next one started right away, then all failed
+	a, agate = newProc("a", someErr)
+	b, bgate = newProc("b", someErr)
+	close(agate)
+	close(bgate)
+	_, err = read(procs.Hedged{[]procs.Proc{a, b}, time.Hour})
+	assert.Equal(t, someErr, err)
+}
+
+func TestHedgedFinish(t *testing.T) {
+	testutil.TestFinishErrForward(t, func(proc procs.Proc) testutil.Finisher {
+		return procs.Hedged{Procs: []procs.Proc{procs.Nop, proc}}
+	})
+}
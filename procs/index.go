@@ -1,7 +1,9 @@
 package procs
 
 import (
+	"crypto/sha256"
 	"errors"
+	"hash"
 	"io"
 	"sort"
 	"sync"
@@ -21,6 +23,8 @@ type indexProc struct {
 	w        io.Writer
 	order    seriessort.Series
 	orderMu  sync.Mutex
+	offsets  bool
+	offset   int64
 	finals   map[checksum.Hash]*finals
 	finalsMu sync.RWMutex
 }
@@ -38,6 +42,12 @@ type indexEntry struct {
 	targetSize int
 }
 
+type orderEntry struct {
+	hash checksum.Hash
+	size int
+	hole bool
+}
+
 var (
 	ErrIndexUnprocessedChunk = errors.New("unprocessed chunk")
 	ErrIndexProcessEnded     = errors.New("process already ended")
@@ -55,6 +65,10 @@ func NewIndexProc(w io.Writer) IndexProc {
 func (idx *indexProc) Process(c *scat.Chunk) <-chan Res {
 	idx.setOrder(c)
 	ch := make(chan Res, 1)
+	if idx.isHole(c) {
+		close(ch)
+		return ch
+	}
 	defer close(ch)
 	idx.finalsMu.Lock()
 	defer idx.finalsMu.Unlock()
@@ -91,6 +105,9 @@ func (idx *indexProc) ProcessFinal(c, final *scat.Chunk) error {
 }
 
 func (idx *indexProc) ProcessEnd(c *scat.Chunk) (err error) {
+	if idx.isHole(c) {
+		return idx.flush()
+	}
 	err = idx.setFinalsComplete(c)
 	if err != nil {
 		return
@@ -138,8 +155,16 @@ func (idx *indexProc) flush() (err error) {
 		idx.order.Drop(i)
 	}()
 	for n := len(sorted); i < n; i++ {
-		hash := sorted[i].(checksum.Hash)
-		finals, ok := idx.completeFinals(hash)
+		oe := sorted[i].(orderEntry)
+		if oe.hole {
+			_, err = index.WriteHole(idx.w, idx.offset, oe.size)
+			if err != nil {
+				return
+			}
+			idx.offset += int64(oe.size)
+			continue
+		}
+		finals, ok := idx.completeFinals(oe.hash)
 		if !ok {
 			return
 		}
@@ -150,10 +175,11 @@ func (idx *indexProc) flush() (err error) {
 		sort.Slice(entries, func(i, j int) bool {
 			return num(i) < num(j)
 		})
-		err = writeEntries(idx.w, entries)
+		err = idx.writeEntries(entries)
 		if err != nil {
 			return
 		}
+		idx.offset += int64(oe.size)
 	}
 	return
 }
@@ -172,12 +198,21 @@ func (idx *indexProc) completeFinals(hash checksum.Hash) (f *finals, ok bool) {
 func (idx *indexProc) setOrder(c *scat.Chunk) {
 	idx.orderMu.Lock()
 	defer idx.orderMu.Unlock()
-	idx.order.Add(c.Num(), c.Hash())
+	idx.order.Add(c.Num(), orderEntry{c.Hash(), c.TargetSize(), idx.isHole(c)})
+}
+
+// Holes can only be told apart from other chunks with offsets.
+func (idx *indexProc) isHole(c *scat.Chunk) bool {
+	return idx.offsets && IsHole(c)
 }
 
-func writeEntries(w io.Writer, entries []indexEntry) (err error) {
-	for _, entry := range entries {
-		_, err = index.Write(w, entry.hash, entry.targetSize)
+func (idx *indexProc) writeEntries(entries []indexEntry) (err error) {
+	for _, e := range entries {
+		if idx.offsets {
+			_, err = index.WriteOffset(idx.w, e.hash, e.targetSize, idx.offset)
+		} else {
+			_, err = index.Write(idx.w, e.hash, e.targetSize)
+		}
 		if err != nil {
 			return
 		}
@@ -190,3 +225,99 @@ var IndexUnproc Proc = ChunkIterFunc(indexUnprocess)
 func indexUnprocess(c *scat.Chunk) scat.ChunkIter {
 	return index.NewScanner(c.Num(), c.Data().Reader())
 }
+
+type headerIndexProc struct {
+	*indexProc
+	hw       *headerWriter
+	stream   seriessort.Series
+	streamMu sync.Mutex
+	sum      hash.Hash
+	size     int64
+	finished bool
+}
+
+// Writes hdr before entries, and the size and SHA-256 of the stream of chunks
+// received in Process after them. hdr may be completed until the first entry
+// gets written. Entries record the offset in the stream of their chunk, from
+// its target size.
+func NewHeaderIndexProc(w io.Writer, hdr *index.Header) IndexProc {
+	hw := &headerWriter{w: w, hdr: hdr}
+	idx := NewIndexProc(hw).(*indexProc)
+	idx.offsets = true
+	return &headerIndexProc{
+		indexProc: idx,
+		hw:        hw,
+		sum:       sha256.New(),
+	}
+}
+
+func (idx *headerIndexProc) Process(c *scat.Chunk) <-chan Res {
+	err := idx.addStream(c)
+	if err != nil {
+		return SingleRes(c, err)
+	}
+	return idx.indexProc.Process(c)
+}
+
+func (idx *headerIndexProc) addStream(c *scat.Chunk) error {
+	b, err := c.Data().Bytes()
+	if err != nil {
+		return err
+	}
+	idx.streamMu.Lock()
+	defer idx.streamMu.Unlock()
+	idx.stream.Add(c.Num(), b)
+	sorted := idx.stream.Sorted()
+	idx.stream.Drop(len(sorted))
+	for _, val := range sorted {
+		b := val.([]byte)
+		idx.sum.Write(b)
+		idx.size += int64(len(b))
+	}
+	return nil
+}
+
+func (idx *headerIndexProc) Finish() (err error) {
+	err = idx.indexProc.Finish()
+	if err != nil {
+		return
+	}
+	idx.streamMu.Lock()
+	defer idx.streamMu.Unlock()
+	if idx.stream.Len() > 0 {
+		return ErrShort
+	}
+	if idx.finished {
+		return
+	}
+	idx.finished = true
+	trailer := index.Header{Size: idx.size}
+	idx.sum.Sum(trailer.Sum[:0])
+	err = idx.hw.writeHeader()
+	if err != nil {
+		return
+	}
+	return index.WriteHeader(idx.hw.w, trailer)
+}
+
+type headerWriter struct {
+	w     io.Writer
+	hdr   *index.Header
+	wrote bool
+}
+
+func (hw *headerWriter) Write(b []byte) (int, error) {
+	err := hw.writeHeader()
+	if err != nil {
+		return 0, err
+	}
+	return hw.w.Write(b)
+}
+
+func (hw *headerWriter) writeHeader() (err error) {
+	if hw.wrote {
+		return
+	}
+	hw.wrote = true
+	return index.WriteHeader(hw.w, *hw.hdr)
+}
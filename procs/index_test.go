@@ -10,6 +10,7 @@ import (
 	assert "github.com/stretchr/testify/require"
 	"github.com/Roman2K/scat"
 	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/index"
 	"github.com/Roman2K/scat/procs"
 	"github.com/Roman2K/scat/testutil"
 )
@@ -73,6 +74,98 @@ func TestIndex(t *testing.T) {
 	assert.Equal(t, 4, nlines())
 }
 
+func TestHeaderIndex(t *testing.T) {
+	buf := &bytes.Buffer{}
+	hdr := &index.Header{Version: index.Version}
+	idx := procs.NewHeaderIndexProc(buf, hdr)
+	end := func(num int, contents string) {
+		c := testIndexChunk(num, len(contents), sum(contents))
+		c = c.WithData(scat.BytesData(contents))
+		for res := range idx.Process(c) {
+			assert.NoError(t, res.Err)
+			err := idx.ProcessFinal(c, c)
+			assert.NoError(t, err)
+		}
+		err := idx.ProcessEnd(c)
+		assert.NoError(t, err)
+	}
+
+	// completed until first entry
+	hdr.Proc = "split | index -"
+	end(1, "bb")
+	end(0, "a")
+	hdr.Proc = "foo"
+	end(2, "a")
+	err := idx.Finish()
+	assert.NoError(t, err)
+
+	// idempotence
+	err = idx.Finish()
+	assert.NoError(t, err)
+
+	expectedIndex := "" +
+		"#version 3\n" +
+		"#proc \"split | index -\"\n" +
+		sumStr("a") + " 1 0\n" +
+		sumStr("bb") + " 2 1\n" +
+		sumStr("a") + " 1 3\n" +
+		"#size 4\n" +
+		"#sha256 " + sumStr("abba") + "\n"
+	assert.Equal(t, expectedIndex, buf.String())
+
+	// read back
+	h, err := index.ReadHeader(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, index.Header{
+		Version: 3,
+		Proc:    "split | index -",
+		Size:    4,
+		Sum:     sum("abba"),
+	}, h)
+}
+
+func TestHeaderIndexHoles(t *testing.T) {
+	buf := &bytes.Buffer{}
+	idx := procs.NewHeaderIndexProc(buf, &index.Header{})
+	end := func(num int, contents string, count int) {
+		c := testIndexChunk(num, len(contents), sum(contents))
+		c = c.WithData(scat.BytesData(contents))
+		_, err := testutil.ReadChunks(procs.Sparse.Process(c))
+		assert.NoError(t, err)
+		n := 0
+		for res := range idx.Process(c) {
+			assert.NoError(t, res.Err)
+			err := idx.ProcessFinal(c, c)
+			assert.NoError(t, err)
+			n++
+		}
+		assert.Equal(t, count, n)
+		err = idx.ProcessEnd(c)
+		assert.NoError(t, err)
+	}
+
+	end(0, "a", 1)
+	end(2, "\x00\x00", 0)
+	end(1, "\x00\x00\x00", 0)
+	end(3, "bb", 1)
+	err := idx.Finish()
+	assert.NoError(t, err)
+
+	expectedIndex := "" +
+		sumStr("a") + " 1 0\n" +
+		"#hole 1 3\n" +
+		"#hole 4 2\n" +
+		sumStr("bb") + " 2 6\n" +
+		"#size 8\n" +
+		"#sha256 " + sumStr("a\x00\x00\x00\x00\x00bb") + "\n"
+	assert.Equal(t, expectedIndex, buf.String())
+
+	// holes aren't chunks
+	hashes, err := index.ReadHashes(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []checksum.Hash{sum("a"), sum("bb")}, hashes)
+}
+
 func TestIndexSameChunkNewData(t *testing.T) {
 	buf := &bytes.Buffer{}
 	idx := procs.NewIndexProc(buf)
@@ -0,0 +1,85 @@
+package procs
+
+import (
+	"io"
+	"sync"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/index"
+)
+
+// Byte range [Start, End) of the stream to restore, End 0 meaning until the
+// end. Shared by index unprocs, which tell the size of the stream, and joins,
+// which write chunks within the range, filling holes up to its end.
+type ByteRange struct {
+	Start, End int64
+	size       int64
+	sizeMu     sync.Mutex
+}
+
+func (r *ByteRange) setSize(size int64) {
+	r.sizeMu.Lock()
+	defer r.sizeMu.Unlock()
+	r.size = size
+}
+
+// Offset the output ends at, 0 if unknown.
+func (r *ByteRange) end() int64 {
+	if r.End != 0 {
+		return r.End
+	}
+	r.sizeMu.Lock()
+	defer r.sizeMu.Unlock()
+	return r.size
+}
+
+// Part of b, starting at off in the stream, within the range, and its offset.
+func (r *ByteRange) trim(b []byte, off int64) ([]byte, int64) {
+	start, end := off, off+int64(len(b))
+	if start < r.Start {
+		start = r.Start
+	}
+	if r.End != 0 && end > r.End {
+		end = r.End
+	}
+	if start >= end {
+		return nil, start
+	}
+	return b[start-off : end-off], start
+}
+
+// Like IndexUnproc but also tells rng the size of the stream.
+func NewIndexUnproc(rng *ByteRange) Proc {
+	return ChunkIterFunc(func(c *scat.Chunk) scat.ChunkIter {
+		scan := index.NewScanner(c.Num(), c.Data().Reader())
+		return sizeIter{scan, rng}
+	})
+}
+
+// Like NewIndexUnproc but only emits entries of chunks overlapping rng, as
+// told by the offsets recorded in the index.
+func NewIndexRangeUnproc(rng *ByteRange) Proc {
+	return ChunkIterFunc(func(c *scat.Chunk) scat.ChunkIter {
+		scan := index.NewRangeScanner(c.Num(), c.Data().Reader(),
+			rng.Start, rng.End,
+		)
+		return sizeIter{scan, rng}
+	})
+}
+
+type sizeIter struct {
+	index.Scanner
+	rng *ByteRange
+}
+
+func (it sizeIter) Next() bool {
+	ok := it.Scanner.Next()
+	if !ok {
+		it.rng.setSize(it.Header().Size)
+	}
+	return ok
+}
+
+func NewRangeJoin(w io.Writer, rng *ByteRange) Proc {
+	return NewWindowJoin(w, nil, rng)
+}
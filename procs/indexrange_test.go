@@ -0,0 +1,87 @@
+package procs_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/testutil"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestIndexRange(t *testing.T) {
+	idx := "" +
+		fmt.Sprintf("%s 3 0\n", sumStr("abc")) +
+		fmt.Sprintf("%s 3 3\n", sumStr("def")) +
+		fmt.Sprintf("%s 2 6\n", sumStr("gh")) +
+		"#size 8\n"
+	data := map[string]string{
+		sumStr("abc"): "abc",
+		sumStr("def"): "def",
+		sumStr("gh"):  "gh",
+	}
+
+	restore := func(start, end int64) string {
+		rng := &procs.ByteRange{Start: start, End: end}
+		uindex := procs.NewIndexRangeUnproc(rng)
+		seed := scat.NewChunk(0, scat.BytesData(idx))
+		chunks, err := testutil.ReadChunks(uindex.Process(seed))
+		assert.NoError(t, err)
+		out := &bytes.Buffer{}
+		join := procs.NewRangeJoin(out, rng)
+		for i := len(chunks) - 1; i >= 0; i-- {
+			c := chunks[i]
+			c = c.WithData(scat.BytesData(data[fmt.Sprintf("%x", c.Hash())]))
+			_, err = testutil.ReadChunks(join.Process(c))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, join.Finish())
+		return out.String()
+	}
+
+	assert.Equal(t, "abcdefgh", restore(0, 0))
+	assert.Equal(t, "cdefg", restore(2, 7))
+	assert.Equal(t, "e", restore(4, 5))
+	assert.Equal(t, "fgh", restore(5, 0))
+	assert.Equal(t, "", restore(8, 0))
+}
+
+func TestJoinHoles(t *testing.T) {
+	idx := "" +
+		"#hole 0 2\n" +
+		fmt.Sprintf("%s 3 2\n", sumStr("abc")) +
+		"#hole 5 1\n" +
+		fmt.Sprintf("%s 2 6\n", sumStr("de")) +
+		"#hole 8 3\n" +
+		"#size 11\n"
+	data := map[string]string{
+		sumStr("abc"): "abc",
+		sumStr("de"):  "de",
+	}
+
+	restore := func(rng *procs.ByteRange, uindex procs.Proc) string {
+		seed := scat.NewChunk(0, scat.BytesData(idx))
+		chunks, err := testutil.ReadChunks(uindex.Process(seed))
+		assert.NoError(t, err)
+		out := &bytes.Buffer{}
+		join := procs.NewWindowJoin(out, nil, rng)
+		for _, c := range chunks {
+			c = c.WithData(scat.BytesData(data[fmt.Sprintf("%x", c.Hash())]))
+			_, err = testutil.ReadChunks(join.Process(c))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, join.Finish())
+		return out.String()
+	}
+
+	rng := &procs.ByteRange{}
+	assert.Equal(t, "\x00\x00abc\x00de\x00\x00\x00",
+		restore(rng, procs.NewIndexUnproc(rng)),
+	)
+	rng = &procs.ByteRange{Start: 4, End: 10}
+	assert.Equal(t, "c\x00de\x00\x00",
+		restore(rng, procs.NewIndexRangeUnproc(rng)),
+	)
+}
@@ -2,8 +2,114 @@ package procs
 
 import (
 	"io"
+
+	"github.com/Roman2K/scat"
 )
 
 func NewJoin(w io.Writer) Proc {
-	return NewBacklog(1, Chain{&Sort{}, WriterTo{w}})
+	return NewWindowJoin(w, nil, nil)
+}
+
+// Like NewJoin but holding chunks within win and writing them within rng,
+// either of which may be nil.
+func NewWindowJoin(w io.Writer, win *SortWindow, rng *ByteRange) Proc {
+	if rng == nil {
+		rng = &ByteRange{}
+	}
+	wt := &streamWriterTo{w: w, rng: rng, pos: rng.Start}
+	return NewBacklog(1, Chain{&Sort{Window: win}, wt})
+}
+
+// Writes chunks in order at their offset in the stream, if known, skipping
+// holes in between: seeking past them if w supports it, writing zeros
+// otherwise.
+type streamWriterTo struct {
+	w        io.Writer
+	rng      *ByteRange
+	pos      int64
+	seeked   bool
+	finished bool
+}
+
+func (wt *streamWriterTo) Process(c *scat.Chunk) <-chan Res {
+	err := wt.write(c)
+	return SingleRes(c, err)
+}
+
+func (wt *streamWriterTo) write(c *scat.Chunk) (err error) {
+	off, ok := chunkOffset(c)
+	if !ok {
+		off = wt.pos
+	}
+	err = wt.skip(off)
+	if err != nil {
+		return
+	}
+	b, err := c.Data().Bytes()
+	if err != nil {
+		return
+	}
+	trimmed, _ := wt.rng.trim(b, off)
+	if len(trimmed) > 0 {
+		_, err = wt.w.Write(trimmed)
+	}
+	if end := off + int64(len(b)); end > wt.pos {
+		wt.pos = end
+	}
+	return
+}
+
+func (wt *streamWriterTo) skip(to int64) (err error) {
+	from := wt.pos
+	if end := wt.rng.End; end != 0 && to > end {
+		to = end
+	}
+	if from < wt.rng.Start {
+		from = wt.rng.Start
+	}
+	n := to - from
+	if n <= 0 {
+		return
+	}
+	wt.pos = to
+	if s, ok := wt.w.(io.Seeker); ok {
+		if _, err := s.Seek(n, io.SeekCurrent); err == nil {
+			wt.seeked = true
+			return nil
+		}
+	}
+	_, err = io.CopyN(wt.w, zeros{}, n)
+	return
+}
+
+func (wt *streamWriterTo) Finish() (err error) {
+	if wt.finished {
+		return
+	}
+	wt.finished = true
+	end := wt.rng.end()
+	if end == 0 {
+		return
+	}
+	err = wt.skip(end)
+	if err != nil || !wt.seeked {
+		return
+	}
+	if t, ok := wt.w.(truncater); ok {
+		err = t.Truncate(end - wt.rng.Start)
+	}
+	return
+}
+
+type truncater interface {
+	Truncate(int64) error
+}
+
+type zeros struct{}
+
+func (zeros) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
 }
@@ -0,0 +1,69 @@
+package procs
+
+import (
+	"errors"
+	"io"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/index"
+)
+
+var errUnknownOffset = errors.New("unknown chunk offset, missing from index")
+
+type joinFile struct {
+	w   io.WriterAt
+	rng *ByteRange
+}
+
+// Writes chunks at their offset in the stream as recorded in the index, in
+// whatever order they come in, without buffering. Chunks get trimmed to rng,
+// if not nil, and written relative to its start. Holes are left unwritten,
+// up to the end of the stream if w may be truncated.
+func NewJoinFile(w io.WriterAt, rng *ByteRange) Proc {
+	if rng == nil {
+		rng = &ByteRange{}
+	}
+	return joinFile{w: w, rng: rng}
+}
+
+func (jf joinFile) Process(c *scat.Chunk) <-chan Res {
+	return InplaceFunc(jf.process).Process(c)
+}
+
+func (jf joinFile) process(c *scat.Chunk) (err error) {
+	off, ok := chunkOffset(c)
+	if !ok {
+		return errUnknownOffset
+	}
+	b, err := c.Data().Bytes()
+	if err != nil {
+		return
+	}
+	b, off = jf.rng.trim(b, off)
+	if len(b) == 0 {
+		return
+	}
+	_, err = jf.w.WriteAt(b, off-jf.rng.Start)
+	return
+}
+
+func (jf joinFile) Finish() error {
+	end := jf.rng.end()
+	t, ok := jf.w.(truncater)
+	if end == 0 || !ok {
+		return nil
+	}
+	return t.Truncate(end - jf.rng.Start)
+}
+
+// Parity-joined chunks have the offset of the first of their group.
+func chunkOffset(c *scat.Chunk) (off int64, ok bool) {
+	off, ok = index.Offset(c)
+	if ok {
+		return
+	}
+	if group, isGroup := GetGroup(c); isGroup && len(group) > 0 {
+		return chunkOffset(group[0])
+	}
+	return
+}
@@ -0,0 +1,77 @@
+package procs_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/testutil"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestJoinFile(t *testing.T) {
+	idx := "" +
+		fmt.Sprintf("%s 3 0\n", sumStr("abc")) +
+		fmt.Sprintf("%s 3 3\n", sumStr("def")) +
+		fmt.Sprintf("%s 2 6\n", sumStr("gh"))
+	data := map[string]string{
+		sumStr("abc"): "abc",
+		sumStr("def"): "def",
+		sumStr("gh"):  "gh",
+	}
+
+	restore := func(rng *procs.ByteRange) string {
+		f, err := ioutil.TempFile("", "")
+		assert.NoError(t, err)
+		defer os.Remove(f.Name())
+		defer f.Close()
+		uindex := procs.IndexUnproc
+		if rng != nil {
+			uindex = procs.NewIndexRangeUnproc(rng)
+		}
+		seed := scat.NewChunk(0, scat.BytesData(idx))
+		chunks, err := testutil.ReadChunks(uindex.Process(seed))
+		assert.NoError(t, err)
+		join := procs.NewJoinFile(f, rng)
+		for i := len(chunks) - 1; i >= 0; i-- {
+			c := chunks[i]
+			c = c.WithData(scat.BytesData(data[fmt.Sprintf("%x", c.Hash())]))
+			_, err = testutil.ReadChunks(join.Process(c))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, join.Finish())
+		b, err := ioutil.ReadFile(f.Name())
+		assert.NoError(t, err)
+		return string(b)
+	}
+
+	assert.Equal(t, "abcdefgh", restore(nil))
+	assert.Equal(t, "cdefg", restore(&procs.ByteRange{Start: 2, End: 7}))
+	assert.Equal(t, "fgh", restore(&procs.ByteRange{Start: 5}))
+}
+
+func TestJoinFileGroupOffset(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	seed := scat.NewChunk(0, scat.BytesData(
+		fmt.Sprintf("%s 1 2\n%s 1 2\n", sumStr("x"), sumStr("y")),
+	))
+	shards, err := testutil.ReadChunks(procs.IndexUnproc.Process(seed))
+	assert.NoError(t, err)
+	joined := testutil.Group(shards).WithData(scat.BytesData("ab"))
+	join := procs.NewJoinFile(f, nil)
+	_, err = testutil.ReadChunks(join.Process(joined))
+	assert.NoError(t, err)
+	b, err := ioutil.ReadFile(f.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, "\x00\x00ab", string(b))
+
+	// unknown offset
+	_, err = testutil.ReadChunks(join.Process(scat.NewChunk(0, nil)))
+	assert.Error(t, err)
+}
@@ -0,0 +1,41 @@
+package procs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"github.com/Roman2K/scat"
+	"github.com/pierrec/lz4"
+)
+
+type Lz4 struct {
+	Level int // 1-9 for high compression, 0 for fast
+}
+
+func (lz Lz4) Proc() Proc {
+	return ChunkFunc(lz.process)
+}
+
+func (lz Lz4) Unproc() Proc {
+	return ChunkFunc(lz.unprocess)
+}
+
+func (lz Lz4) process(c *scat.Chunk) (new *scat.Chunk, err error) {
+	buf := &bytes.Buffer{}
+	w := lz4.NewWriter(buf)
+	w.Header = lz4.Header{CompressionLevel: lz.Level}
+	_, err = io.Copy(w, c.Data().Reader())
+	if err != nil {
+		return
+	}
+	err = w.Close()
+	new = c.WithData(scat.BytesData(buf.Bytes()))
+	return
+}
+
+func (Lz4) unprocess(c *scat.Chunk) (new *scat.Chunk, err error) {
+	buf, err := ioutil.ReadAll(lz4.NewReader(c.Data().Reader()))
+	new = c.WithData(scat.BytesData(buf))
+	return
+}
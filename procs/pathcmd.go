@@ -10,20 +10,34 @@ import (
 	"github.com/Roman2K/scat/tmpdedup"
 )
 
-type pathCmdIn struct {
-	newCmd PathCmdInFn
-	tmp    *tmpdedup.Dir
+type pathIn struct {
+	fn  PathInFn
+	tmp *tmpdedup.Dir
 }
 
 type PathCmdInFn func(*scat.Chunk, string) (*exec.Cmd, error)
 
+type PathInFn func(*scat.Chunk, string) error
+
 func NewPathCmdIn(newCmd PathCmdInFn, tmp *tmpdedup.Dir) Proc {
-	return InplaceFunc(pathCmdIn{newCmd, tmp}.process)
+	return NewPathIn(func(c *scat.Chunk, path string) error {
+		cmd, err := newCmd(c, path)
+		if err != nil {
+			return err
+		}
+		return cmd.Run()
+	}, tmp)
+}
+
+// Calls fn with the path of a temporary file holding the chunk's data,
+// shared with other procs processing the same data concurrently.
+func NewPathIn(fn PathInFn, tmp *tmpdedup.Dir) Proc {
+	return InplaceFunc(pathIn{fn, tmp}.process)
 }
 
-func (cmdp pathCmdIn) process(c *scat.Chunk) error {
+func (p pathIn) process(c *scat.Chunk) error {
 	filename := fmt.Sprintf("%x", c.Hash())
-	path, wg, err := cmdp.tmp.Get(filename, func(path string) (err error) {
+	path, wg, err := p.tmp.Get(filename, func(path string) (err error) {
 		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
 		if err != nil {
 			return
@@ -36,9 +50,5 @@ func (cmdp pathCmdIn) process(c *scat.Chunk) error {
 		return err
 	}
 	defer wg.Done()
-	cmd, err := cmdp.newCmd(c, path)
-	if err != nil {
-		return err
-	}
-	return cmd.Run()
+	return p.fn(c, path)
 }
@@ -11,6 +11,7 @@ import (
 var (
 	ErrShort           = errors.New("missing final chunks")
 	ErrUnreturnedSlots = errors.New("unreturned slots left")
+	ErrNoChunk         = errors.New("no chunk returned")
 )
 
 var Nop Proc
@@ -92,6 +93,23 @@ func Process(proc Proc, chunk *scat.Chunk) error {
 	return proc.Finish()
 }
 
+// Returns the first chunk output for chunk. Unlike Process, leaves proc to be
+// finished by the caller, as it may be long-lived.
+func ProcessResult(proc Proc, chunk *scat.Chunk) (res *scat.Chunk, err error) {
+	for r := range proc.Process(chunk) {
+		if r.Err != nil && err == nil {
+			err = r.Err
+		}
+		if res == nil {
+			res = r.Chunk
+		}
+	}
+	if err == nil && res == nil {
+		err = ErrNoChunk
+	}
+	return
+}
+
 func SingleRes(c *scat.Chunk, err error) <-chan Res {
 	ch := make(chan Res, 1)
 	defer close(ch)
@@ -1,13 +1,17 @@
 package procs
 
 import (
+	"fmt"
+	"io/ioutil"
 	"sync"
 
 	"github.com/Roman2K/scat"
 	"github.com/Roman2K/scat/seriessort"
+	"github.com/Roman2K/scat/tmpdedup"
 )
 
 type Sort struct {
+	Window   *SortWindow
 	series   seriessort.Series
 	seriesMu sync.Mutex
 }
@@ -17,11 +21,29 @@ func (s *Sort) Process(c *scat.Chunk) <-chan Res {
 	s.series.Add(c.Num(), c)
 	sorted := s.series.Sorted()
 	s.series.Drop(len(sorted))
+	var err error
+	if len(sorted) == 0 && s.Window != nil {
+		var held interface{}
+		held, err = s.Window.hold(s, c)
+		s.series.Add(c.Num(), held)
+	}
 	s.seriesMu.Unlock()
+	if err != nil {
+		return SingleRes(c, err)
+	}
 	ch := make(chan Res, len(sorted))
 	defer close(ch)
-	for _, val := range sorted {
-		ch <- Res{Chunk: val.(*scat.Chunk)}
+	for i, val := range sorted {
+		var (
+			c   *scat.Chunk
+			err error
+		)
+		if i == 0 || s.Window == nil {
+			c = val.(*scat.Chunk)
+		} else {
+			c, err = s.Window.release(val)
+		}
+		ch <- Res{Chunk: c, Err: err}
 	}
 	return ch
 }
@@ -35,3 +57,113 @@ func (s *Sort) Finish() error {
 	}
 	return nil
 }
+
+// Limits chunks held by Sort until the ones before them come in, zero meaning
+// unlimited. Past either limit, Wait blocks and, if Spill isn't nil, further
+// chunks get written there instead of being kept in memory.
+type SortWindow struct {
+	MaxChunks int
+	MaxBytes  int64
+	Spill     *tmpdedup.Dir
+	chunks    int
+	bytes     int64
+	mu        sync.Mutex
+	cond      *sync.Cond
+}
+
+func NewSortWindow() (w *SortWindow) {
+	w = &SortWindow{}
+	w.cond = sync.NewCond(&w.mu)
+	return
+}
+
+type spilled struct {
+	chunk *scat.Chunk
+	size  int64
+	path  string
+	wg    *sync.WaitGroup
+}
+
+// Blocks while the window is full. Meant to be called by a source of chunks
+// in order, upstream of Sort, to hold it back until the chunks Sort is missing
+// come in.
+func (w *SortWindow) Wait() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.full() {
+		w.cond.Wait()
+	}
+}
+
+func (w *SortWindow) full() bool {
+	return (w.MaxChunks > 0 && w.chunks >= w.MaxChunks) ||
+		(w.MaxBytes > 0 && w.bytes >= w.MaxBytes)
+}
+
+func (w *SortWindow) hold(s *Sort, c *scat.Chunk) (
+	held interface{}, err error,
+) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	size := dataSize(c)
+	full := w.full()
+	w.chunks++
+	w.bytes += size
+	held = c
+	if !full || w.Spill == nil {
+		return
+	}
+	name := fmt.Sprintf("sort-%p-%d", s, c.Num())
+	path, wg, err := w.Spill.Get(name, func(path string) error {
+		b, err := c.Data().Bytes()
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, b, 0644)
+	})
+	if err != nil {
+		return
+	}
+	held = &spilled{
+		chunk: c.WithData(nil),
+		size:  size,
+		path:  path,
+		wg:    wg,
+	}
+	return
+}
+
+func (w *SortWindow) release(held interface{}) (c *scat.Chunk, err error) {
+	var size int64
+	switch v := held.(type) {
+	case *scat.Chunk:
+		c, size = v, dataSize(v)
+	case *spilled:
+		size = v.size
+		var b []byte
+		b, err = ioutil.ReadFile(v.path)
+		v.wg.Done()
+		c = v.chunk.WithData(scat.BytesData(b))
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.chunks--
+	w.bytes -= size
+	w.cond.Broadcast()
+	return
+}
+
+func dataSize(c *scat.Chunk) int64 {
+	if sz, ok := c.Data().(scat.Sizer); ok {
+		return int64(sz.Size())
+	}
+	return 0
+}
+
+// Applies backpressure for w: see SortWindow.Wait.
+func NewSortWait(w *SortWindow) Proc {
+	return ProcFunc(func(c *scat.Chunk) <-chan Res {
+		w.Wait()
+		return SingleRes(c, nil)
+	})
+}
@@ -1,11 +1,14 @@
 package procs_test
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/Roman2K/scat"
 	"github.com/Roman2K/scat/procs"
 	"github.com/Roman2K/scat/testutil"
+	"github.com/Roman2K/scat/tmpdedup"
 	assert "github.com/stretchr/testify/require"
 )
 
@@ -34,3 +37,51 @@ func TestSortFinish(t *testing.T) {
 	err = sortp.Finish()
 	assert.NoError(t, err)
 }
+
+func TestSortWindow(t *testing.T) {
+	tmp, err := tmpdedup.TempDir("")
+	assert.NoError(t, err)
+	defer tmp.Finish()
+	win := procs.NewSortWindow()
+	win.MaxChunks = 2
+	win.Spill = tmp
+	sortp := &procs.Sort{Window: win}
+	process := func(num int) []*scat.Chunk {
+		c := scat.NewChunk(num, scat.BytesData(fmt.Sprintf("data%d", num)))
+		chunks, err := testutil.ReadChunks(sortp.Process(c))
+		assert.NoError(t, err)
+		return chunks
+	}
+
+	assert.Equal(t, 0, len(process(2)))
+	assert.Equal(t, 0, len(process(1)))
+	assert.Equal(t, 0, tmp.TmpMan().Len())
+	assert.Equal(t, 0, len(process(3)))
+	assert.Equal(t, 1, tmp.TmpMan().Len())
+
+	// backpressure
+	waited := make(chan struct{})
+	go func() {
+		win.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("window full but didn't wait")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	chunks := process(0)
+	<-waited
+	data := []string{}
+	for i, c := range chunks {
+		assert.Equal(t, i, c.Num())
+		b, err := c.Data().Bytes()
+		assert.NoError(t, err)
+		data = append(data, string(b))
+	}
+	assert.Equal(t, []string{"data0", "data1", "data2", "data3"}, data)
+	tmp.TmpMan().Wait()
+	assert.Equal(t, 0, tmp.TmpMan().Len())
+	assert.NoError(t, sortp.Finish())
+}
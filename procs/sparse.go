@@ -0,0 +1,28 @@
+package procs
+
+import (
+	"github.com/Roman2K/scat"
+)
+
+// Marks all-zero chunks as holes, for a header index proc to record as such
+// instead of passing them on.
+var Sparse Proc = InplaceFunc(sparseProcess)
+
+func sparseProcess(c *scat.Chunk) (err error) {
+	b, err := c.Data().Bytes()
+	if err != nil {
+		return
+	}
+	for _, x := range b {
+		if x != 0 {
+			return
+		}
+	}
+	c.Meta().Set(metaHole, true)
+	return
+}
+
+func IsHole(c *scat.Chunk) bool {
+	hole, _ := c.Meta().Get(metaHole).(bool)
+	return hole
+}
@@ -0,0 +1,23 @@
+package procs_test
+
+import (
+	"testing"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/testutil"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestSparse(t *testing.T) {
+	isHole := func(data string) bool {
+		c := scat.NewChunk(0, scat.BytesData(data))
+		chunks, err := testutil.ReadChunks(procs.Sparse.Process(c))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(chunks))
+		return procs.IsHole(chunks[0])
+	}
+	assert.True(t, isHole("\x00\x00\x00"))
+	assert.False(t, isHole("\x00a\x00"))
+	assert.False(t, procs.IsHole(scat.NewChunk(0, nil)))
+}
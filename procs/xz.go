@@ -0,0 +1,62 @@
+package procs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/Roman2K/scat"
+	"github.com/ulikunitz/xz"
+)
+
+// Dictionary sizes of xz(1) presets
+var xzDictCaps = [...]int{
+	256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20,
+	8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20,
+}
+
+type Xz struct {
+	Level int // 1-9 as in xz(1), 0 for default
+}
+
+func (x Xz) Proc() Proc {
+	return ChunkFunc(x.process)
+}
+
+func (x Xz) Unproc() Proc {
+	return ChunkFunc(x.unprocess)
+}
+
+func (x Xz) process(c *scat.Chunk) (new *scat.Chunk, err error) {
+	level := x.Level
+	if level == 0 {
+		level = 6
+	}
+	if level < 0 || level >= len(xzDictCaps) {
+		err = fmt.Errorf("invalid xz level %d", x.Level)
+		return
+	}
+	buf := &bytes.Buffer{}
+	w, err := xz.WriterConfig{DictCap: xzDictCaps[level]}.NewWriter(buf)
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(w, c.Data().Reader())
+	if err != nil {
+		return
+	}
+	err = w.Close()
+	new = c.WithData(scat.BytesData(buf.Bytes()))
+	return
+}
+
+func (Xz) unprocess(c *scat.Chunk) (new *scat.Chunk, err error) {
+	r, err := xz.NewReader(c.Data().Reader())
+	if err != nil {
+		return
+	}
+	buf, err := ioutil.ReadAll(r)
+	new = c.WithData(scat.BytesData(buf))
+	return
+}
@@ -0,0 +1,87 @@
+package procs
+
+import (
+	"sync"
+
+	"github.com/Roman2K/scat"
+	"github.com/klauspost/compress/zstd"
+)
+
+type Zstd struct {
+	Level int // 1-22 as in zstd(1), 0 for default
+}
+
+func (z Zstd) Proc() Proc {
+	return ChunkFunc(z.process)
+}
+
+func (z Zstd) Unproc() Proc {
+	return ChunkFunc(z.unprocess)
+}
+
+func (z Zstd) process(c *scat.Chunk) (new *scat.Chunk, err error) {
+	level := zstd.SpeedDefault
+	if z.Level != 0 {
+		level = zstd.EncoderLevelFromZstd(z.Level)
+	}
+	b, err := c.Data().Bytes()
+	if err != nil {
+		return
+	}
+	enc, err := zstdEncoder(level)
+	if err != nil {
+		return
+	}
+	new = c.WithData(scat.BytesData(enc.EncodeAll(b, nil)))
+	return
+}
+
+func (Zstd) unprocess(c *scat.Chunk) (new *scat.Chunk, err error) {
+	b, err := c.Data().Bytes()
+	if err != nil {
+		return
+	}
+	dec, err := zstdDecoder()
+	if err != nil {
+		return
+	}
+	buf, err := dec.DecodeAll(b, nil)
+	new = c.WithData(scat.BytesData(buf))
+	return
+}
+
+// Built once and shared by all chunks: EncodeAll and DecodeAll are safe for
+// concurrent use.
+var zstdCoders struct {
+	mu  sync.Mutex
+	enc map[zstd.EncoderLevel]*zstd.Encoder
+	dec *zstd.Decoder
+}
+
+func zstdEncoder(level zstd.EncoderLevel) (enc *zstd.Encoder, err error) {
+	zstdCoders.mu.Lock()
+	defer zstdCoders.mu.Unlock()
+	enc, ok := zstdCoders.enc[level]
+	if ok {
+		return
+	}
+	enc, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+	if err != nil {
+		return
+	}
+	if zstdCoders.enc == nil {
+		zstdCoders.enc = make(map[zstd.EncoderLevel]*zstd.Encoder)
+	}
+	zstdCoders.enc[level] = enc
+	return
+}
+
+func zstdDecoder() (dec *zstd.Decoder, err error) {
+	zstdCoders.mu.Lock()
+	defer zstdCoders.mu.Unlock()
+	if zstdCoders.dec == nil {
+		zstdCoders.dec, err = zstd.NewReader(nil)
+	}
+	dec = zstdCoders.dec
+	return
+}
@@ -11,8 +11,8 @@ import (
 const (
 	pol        = chunker.Pol(0x3DA3358B4DC173)
 	minMin     = 512 * 1024 // chunker.chunkerBufSize
-	defaultMin = chunker.MinSize
-	defaultMax = chunker.MaxSize
+	DefaultMin = chunker.MinSize
+	DefaultMax = chunker.MaxSize
 )
 
 type splitter struct {
@@ -24,7 +24,7 @@ type splitter struct {
 }
 
 func NewSplitter(num int, r io.Reader) scat.ChunkIter {
-	return NewSplitterSize(num, r, defaultMin, defaultMax)
+	return NewSplitterSize(num, r, DefaultMin, DefaultMax)
 }
 
 func NewSplitterSize(num int, r io.Reader, min, max uint) scat.ChunkIter {
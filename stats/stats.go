@@ -78,8 +78,9 @@ func (st *Statsd) WriteTo(w io.Writer) (written int64, err error) {
 	}
 
 	// Headers
-	err = write(fmt.Sprintf("%15s\t%s\t%12s\t%11s\t%10s\t%7s\n",
+	err = write(fmt.Sprintf("%15s\t%s\t%12s\t%11s\t%10s\t%7s\t%9s\t%12s\t%7s\n",
 		"PROC", "INST", "RATE", "USE", "QUOTA", "FILL",
+		"LATENCY", "READ RATE", "ERRORS",
 	))
 	if err != nil {
 		return
@@ -101,13 +102,18 @@ func (st *Statsd) WriteTo(w io.Writer) (written int64, err error) {
 		} else {
 			quotaUse = formatQuota(cnt.Quota.Use, cnt.Quota.Max == 0)
 		}
-		line := fmt.Sprintf("%15s\tx%d\t%12s\t%11s\t%10s\t%7s\n",
+		latency, readRate, errs := formatRead(cnt.Read, now)
+		line := fmt.Sprintf(
+			"%15s\tx%d\t%12s\t%11s\t%10s\t%7s\t%9s\t%12s\t%7s\n",
 			scnt.id,
 			inst,
 			out,
 			quotaUse,
 			formatQuota(cnt.Quota.Max, true),
 			formatQuotaFill(cnt.Quota.Use, cnt.Quota.Max),
+			latency,
+			readRate,
+			errs,
 		)
 		if dead {
 			line = fmt.Sprintf("\x1b[90m%s\x1b[0m", line)
@@ -148,6 +154,23 @@ func formatQuotaFill(used, max uint64) string {
 	return fmt.Sprintf("%.2f%%", pct)
 }
 
+func formatRead(r ReadCounter, now time.Time) (latency, rate, errs string) {
+	if r.Reads == 0 {
+		return
+	}
+	if now.Before(r.OpenUntil) {
+		// Padded before coloring: escape codes would count toward the width
+		latency = fmt.Sprintf("\x1b[31m%9s\x1b[0m", "open")
+	} else if r.Latency > 0 {
+		latency = r.Latency.Truncate(time.Millisecond).String()
+	}
+	if r.Rate > 0 {
+		rate = humanize.IBytes(r.Rate) + "/s"
+	}
+	errs = fmt.Sprintf("%.1f%%", float64(r.Errors)/float64(r.Reads)*100)
+	return
+}
+
 type Counter struct {
 	pos   uint32
 	last  time.Time
@@ -158,6 +181,14 @@ type Counter struct {
 		Init     bool
 		Use, Max uint64
 	}
+	Read ReadCounter
+}
+
+type ReadCounter struct {
+	Latency       time.Duration
+	Rate          uint64
+	Reads, Errors uint64
+	OpenUntil     time.Time
 }
 
 func (cnt *Counter) addInst(delta int32) {
@@ -0,0 +1,295 @@
+package stores
+
+import (
+	"container/list"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+)
+
+// Serves reads of Store from a cache in Dir of up to Max bytes, filled with
+// the chunks written to and read from Store, least recently used evicted
+// first. Cached chunks are keyed by chunk hash and prefixed with the hash of
+// their data as stored, whether or not it's the same: those failing
+// verification are dropped and read from Store instead.
+type Cache struct {
+	Store
+	Dir string
+	Max uint64
+
+	mu      sync.Mutex
+	lru     *list.List // of cacheEntry, most recently used first
+	entries map[checksum.Hash]*list.Element
+	size    uint64
+}
+
+type cacheEntry struct {
+	hash checksum.Hash
+	size uint64
+}
+
+var (
+	_ Store        = &Cache{}
+	_ CachedLister = &Cache{}
+	_ Batcher      = &Cache{}
+	_ Deleter      = &Cache{}
+	_ Quarantiner  = &Cache{}
+)
+
+func NewCache(dir string, max uint64, store Store) *Cache {
+	return &Cache{Store: store, Dir: dir, Max: max}
+}
+
+// Falls back to Ls for stores not caching their listing.
+func (c *Cache) LsCached(fn func(added, removed []LsEntry)) (
+	[]LsEntry, error,
+) {
+	if cl, ok := c.Store.(CachedLister); ok {
+		return cl.LsCached(fn)
+	}
+	return c.Store.Ls()
+}
+
+func (c *Cache) WithBatch(size int) (Store, error) {
+	bat, ok := c.Store.(Batcher)
+	if !ok {
+		return nil, ErrNoBatch
+	}
+	store, err := bat.WithBatch(size)
+	if err != nil {
+		return nil, err
+	}
+	return NewCache(c.Dir, c.Max, store), nil
+}
+
+func (c *Cache) Proc() procs.Proc {
+	return cacheFillProc{c, c.Store.Proc(), true}
+}
+
+func (c *Cache) Unproc() procs.Proc {
+	return procs.Cascade{
+		procs.ChunkFunc(c.unprocess),
+		cacheFillProc{c, c.Store.Unproc(), false},
+	}
+}
+
+func (c *Cache) unprocess(chunk *scat.Chunk) (new *scat.Chunk, err error) {
+	hash := chunk.Hash()
+	c.mu.Lock()
+	c.load()
+	el, ok := c.entries[hash]
+	if ok {
+		c.lru.MoveToFront(el)
+	}
+	c.mu.Unlock()
+	if !ok {
+		err = procs.MissingDataError{errCacheMiss}
+		return
+	}
+	path := c.path(hash)
+	b, err := ioutil.ReadFile(path)
+	if err == nil {
+		b, err = cacheVerify(b)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cache: read error: %v\n", err)
+		c.remove(hash)
+		err = procs.MissingDataError{err}
+		return
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	new = chunk.WithData(scat.BytesData(b))
+	return
+}
+
+var (
+	errCacheMiss    = errors.New("not in cache")
+	errCacheCorrupt = errors.New("cached data doesn't match its hash")
+)
+
+func cacheVerify(b []byte) (data []byte, err error) {
+	var sum checksum.Hash
+	if len(b) < len(sum) {
+		err = errCacheCorrupt
+		return
+	}
+	copy(sum[:], b)
+	data = b[len(sum):]
+	if checksum.SumBytes(data) != sum {
+		err = errCacheCorrupt
+	}
+	return
+}
+
+type cacheFillProc struct {
+	cache *Cache
+	proc  procs.Proc
+	write bool
+}
+
+func (p cacheFillProc) Process(chunk *scat.Chunk) <-chan procs.Res {
+	ch := p.proc.Process(chunk)
+	out := make(chan procs.Res)
+	go func() {
+		defer close(out)
+		var (
+			err error
+			res []procs.Res
+		)
+		for r := range ch {
+			if r.Err != nil && err == nil {
+				err = r.Err
+			}
+			res = append(res, r)
+			out <- r
+		}
+		if err != nil {
+			return
+		}
+		data := chunk.Data()
+		if !p.write {
+			if len(res) != 1 || res[0].Chunk == nil {
+				return
+			}
+			data = res[0].Chunk.Data()
+		}
+		if err := p.cache.put(chunk.Hash(), data); err != nil {
+			fmt.Fprintf(os.Stderr, "cache: write error: %v\n", err)
+		}
+	}()
+	return out
+}
+
+func (p cacheFillProc) Finish() error {
+	return p.proc.Finish()
+}
+
+func (c *Cache) put(hash checksum.Hash, data scat.Data) (err error) {
+	b, err := data.Bytes()
+	if err != nil {
+		return
+	}
+	sum := checksum.SumBytes(b)
+	b = append(sum[:], b...)
+	if uint64(len(b)) > c.Max {
+		return
+	}
+	err = os.MkdirAll(c.Dir, 0755)
+	if err != nil {
+		return
+	}
+	f, err := ioutil.TempFile(c.Dir, ".tmp-")
+	if err != nil {
+		return
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	err = os.Rename(f.Name(), c.path(hash))
+	if err != nil {
+		return
+	}
+	if el, ok := c.entries[hash]; ok {
+		c.size -= el.Value.(cacheEntry).size
+		c.lru.Remove(el)
+	}
+	c.add(cacheEntry{hash, uint64(len(b))})
+	c.evict()
+	return
+}
+
+func (c *Cache) add(e cacheEntry) {
+	c.entries[e.hash] = c.lru.PushFront(e)
+	c.size += e.size
+}
+
+func (c *Cache) evict() {
+	for c.size > c.Max {
+		el := c.lru.Back()
+		e := el.Value.(cacheEntry)
+		c.lru.Remove(el)
+		delete(c.entries, e.hash)
+		c.size -= e.size
+		os.Remove(c.path(e.hash))
+	}
+}
+
+func (c *Cache) remove(hash checksum.Hash) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	if el, ok := c.entries[hash]; ok {
+		c.size -= el.Value.(cacheEntry).size
+		c.lru.Remove(el)
+		delete(c.entries, hash)
+	}
+	os.Remove(c.path(hash))
+}
+
+func (c *Cache) path(hash checksum.Hash) string {
+	return filepath.Join(c.Dir, fmt.Sprintf("%x", hash))
+}
+
+// Recency is carried across runs by modification times, updated on reads.
+// Unreadable caches are started anew.
+func (c *Cache) load() {
+	if c.entries != nil {
+		return
+	}
+	c.lru = list.New()
+	c.entries = make(map[checksum.Hash]*list.Element)
+	c.size = 0
+	fis, err := ioutil.ReadDir(c.Dir)
+	if err != nil {
+		return
+	}
+	sort.Slice(fis, func(i, j int) bool {
+		return fis[i].ModTime().Before(fis[j].ModTime())
+	})
+	for _, fi := range fis {
+		var hash checksum.Hash
+		b, err := hex.DecodeString(fi.Name())
+		if err != nil || !fi.Mode().IsRegular() || hash.LoadSlice(b) != nil {
+			continue
+		}
+		c.add(cacheEntry{hash, uint64(fi.Size())})
+	}
+	c.evict()
+}
+
+func (c *Cache) Delete(hash checksum.Hash) error {
+	del, ok := c.Store.(Deleter)
+	if !ok {
+		return ErrNoDelete
+	}
+	c.remove(hash)
+	return del.Delete(hash)
+}
+
+func (c *Cache) Quarantine(hash checksum.Hash) error {
+	quar, ok := c.Store.(Quarantiner)
+	if !ok {
+		return ErrNoQuarantine
+	}
+	c.remove(hash)
+	return quar.Quarantine(hash)
+}
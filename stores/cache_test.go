@@ -0,0 +1,109 @@
+package stores
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/testutil"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	var (
+		data1 = "abc"
+		data2 = "de"
+		data3 = "f"
+		hash1 = checksum.SumBytes([]byte(data1))
+		hash2 = checksum.SumBytes([]byte(data2))
+		hash3 = checksum.SumBytes([]byte(data3))
+	)
+	mem := NewMem()
+	cache := NewCache(dir, 5+3*checksum.Size, mem)
+	read := func(c *Cache, data string) string {
+		chunk := chunkWithHash(checksum.SumBytes([]byte(data)), "")
+		chunks, err := testutil.ReadChunks(c.Unproc().Process(chunk))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(chunks))
+		b, err := chunks[0].Data().Bytes()
+		assert.NoError(t, err)
+		return string(b)
+	}
+	path := func(hash checksum.Hash) string {
+		return filepath.Join(dir, fmt.Sprintf("%x", hash))
+	}
+	cached := func(hash checksum.Hash) bool {
+		_, err := os.Stat(path(hash))
+		return err == nil
+	}
+
+	// filled on writes
+	err = procs.Process(cache.Proc(), chunkWithHash(hash1, data1))
+	assert.NoError(t, err)
+	mem.Delete(hash1)
+	assert.Equal(t, data1, read(cache, data1))
+
+	// filled on misses
+	mem.Set(hash2, []byte(data2))
+	assert.Equal(t, data2, read(cache, data2))
+	mem.Delete(hash2)
+	assert.Equal(t, data2, read(cache, data2))
+	assert.True(t, cached(hash1))
+	assert.True(t, cached(hash2))
+
+	// least recently used evicted, across runs
+	cache = NewCache(dir, 5+3*checksum.Size, mem)
+	assert.Equal(t, data1, read(cache, data1))
+	mem.Set(hash3, []byte(data3))
+	assert.Equal(t, data3, read(cache, data3))
+	assert.True(t, cached(hash1))
+	assert.False(t, cached(hash2))
+	assert.True(t, cached(hash3))
+
+	// corrupt: read from the store instead
+	err = ioutil.WriteFile(path(hash3), []byte("x"), 0644)
+	assert.NoError(t, err)
+	mem.Set(hash3, []byte(data3))
+	assert.Equal(t, data3, read(cache, data3))
+	b, err := ioutil.ReadFile(path(hash3))
+	assert.NoError(t, err)
+	b, err = cacheVerify(b)
+	assert.NoError(t, err)
+	assert.Equal(t, data3, string(b))
+
+	// stored data not matching the chunk hash, as when encrypted after checksum
+	mem.Set(hash2, []byte("xy"))
+	chunk := chunkWithHash(hash2, "")
+	chunks, err := testutil.ReadChunks(cache.Unproc().Process(chunk))
+	assert.NoError(t, err)
+	mem.Delete(hash2)
+	chunks, err = testutil.ReadChunks(cache.Unproc().Process(chunk))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(chunks))
+	b, err = chunks[0].Data().Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "xy", string(b))
+
+	// deletion
+	err = cache.Delete(hash3)
+	assert.NoError(t, err)
+	assert.False(t, cached(hash3))
+	_, err = testutil.ReadChunks(cache.Unproc().Process(chunkWithHash(hash3, "")))
+	_, ok := err.(procs.MissingDataError)
+	assert.True(t, ok)
+
+	// wrapped store interfaces
+	_, err = cache.WithBatch(2)
+	assert.Equal(t, ErrNoBatch, err)
+	mem.Set(hash1, []byte(data1))
+	ls, err := cache.LsCached(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []LsEntry{{hash1, 3}}, ls)
+}
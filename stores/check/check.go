@@ -0,0 +1,121 @@
+package check
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/index"
+	"github.com/Roman2K/scat/stores"
+	"github.com/Roman2K/scat/stores/copies"
+)
+
+type Status int
+
+const (
+	OK Status = iota
+	Degraded
+	Unrecoverable
+)
+
+func (s Status) String() string {
+	switch s {
+	case OK:
+		return "ok"
+	case Degraded:
+		return "degraded"
+	case Unrecoverable:
+		return "unrecoverable"
+	}
+	return "unknown"
+}
+
+type Check struct {
+	Min          int
+	Data, Parity int
+}
+
+type Result struct {
+	Grouped       bool
+	Chunks        int
+	Missing       []checksum.Hash
+	Under         []Under
+	Degraded      []Group
+	Unrecoverable []Group
+}
+
+type Under struct {
+	Hash   checksum.Hash
+	Copies int
+}
+
+type Group struct {
+	Num     int
+	Missing int
+}
+
+func (res Result) Status() Status {
+	switch {
+	case len(res.Unrecoverable) > 0:
+		return Unrecoverable
+	case len(res.Missing) > 0 && !res.Grouped:
+		return Unrecoverable
+	case len(res.Missing) > 0 || len(res.Degraded) > 0 || len(res.Under) > 0:
+		return Degraded
+	}
+	return OK
+}
+
+func (chk Check) Run(ss []stores.NamedStore, r io.Reader) (
+	res Result, err error,
+) {
+	reg := copies.NewReg()
+	ml := make(stores.MultiLister, len(ss))
+	for i, s := range ss {
+		ml[i] = s
+	}
+	err = ml.AddEntriesTo([]stores.LsEntryAdder{
+		stores.CopiesEntryAdder{Reg: reg},
+	})
+	if err != nil {
+		return
+	}
+	res.Grouped = chk.Parity > 0
+	gsize := chk.Data + chk.Parity
+	missing := 0
+	scan := index.NewScanner(0, r)
+	for scan.Next() {
+		hash := scan.Chunk().Hash()
+		ncopies := reg.List(hash).Len()
+		switch {
+		case ncopies == 0:
+			res.Missing = append(res.Missing, hash)
+			missing++
+		case ncopies < chk.Min:
+			res.Under = append(res.Under, Under{Hash: hash, Copies: ncopies})
+		}
+		res.Chunks++
+		if !res.Grouped || res.Chunks%gsize != 0 {
+			continue
+		}
+		res.addGroup(res.Chunks/gsize-1, missing, chk.Parity)
+		missing = 0
+	}
+	err = scan.Err()
+	if err == nil && res.Grouped && res.Chunks%gsize != 0 {
+		err = fmt.Errorf("%d entries not divisible by group size %d",
+			res.Chunks, gsize,
+		)
+	}
+	return
+}
+
+func (res *Result) addGroup(num, missing, nparity int) {
+	g := Group{Num: num, Missing: missing}
+	switch {
+	case missing > nparity:
+		res.Unrecoverable = append(res.Unrecoverable, g)
+	case missing > 0:
+		res.Degraded = append(res.Degraded, g)
+	}
+}
@@ -0,0 +1,76 @@
+package check_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/index"
+	"github.com/Roman2K/scat/stores"
+	"github.com/Roman2K/scat/stores/check"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	hashes := make([]checksum.Hash, 6)
+	for i := range hashes {
+		hashes[i] = checksum.SumBytes([]byte{byte(i)})
+	}
+	idx := &bytes.Buffer{}
+	for _, h := range hashes {
+		index.Write(idx, h, 1)
+	}
+	run := func(chk check.Check, ss []stores.NamedStore) check.Result {
+		res, err := chk.Run(ss, bytes.NewReader(idx.Bytes()))
+		assert.NoError(t, err)
+		return res
+	}
+
+	mem1 := stores.NewMem()
+	mem2 := stores.NewMem()
+	ss := []stores.NamedStore{{"mem1", mem1}, {"mem2", mem2}}
+	for _, h := range hashes {
+		mem1.Set(h, []byte("x"))
+		mem2.Set(h, []byte("x"))
+	}
+
+	// all there
+	res := run(check.Check{Min: 2}, ss)
+	assert.Equal(t, 6, res.Chunks)
+	assert.Equal(t, check.OK, res.Status())
+
+	// under-replicated
+	mem2.Delete(hashes[0])
+	res = run(check.Check{Min: 2}, ss)
+	assert.Equal(t, []check.Under{{Hash: hashes[0], Copies: 1}}, res.Under)
+	assert.Equal(t, check.Degraded, res.Status())
+	res = run(check.Check{Min: 1}, ss)
+	assert.Equal(t, check.OK, res.Status())
+
+	// missing
+	mem1.Delete(hashes[0])
+	res = run(check.Check{Min: 1}, ss)
+	assert.Equal(t, []checksum.Hash{hashes[0]}, res.Missing)
+	assert.Equal(t, check.Unrecoverable, res.Status())
+
+	// missing, recoverable from parity
+	res = run(check.Check{Min: 1, Data: 2, Parity: 1}, ss)
+	assert.Equal(t, []checksum.Hash{hashes[0]}, res.Missing)
+	assert.Equal(t, []check.Group{{Num: 0, Missing: 1}}, res.Degraded)
+	assert.Equal(t, 0, len(res.Unrecoverable))
+	assert.Equal(t, check.Degraded, res.Status())
+
+	// missing, unrecoverable from parity
+	mem1.Delete(hashes[4])
+	mem2.Delete(hashes[4])
+	mem1.Delete(hashes[5])
+	mem2.Delete(hashes[5])
+	res = run(check.Check{Min: 1, Data: 2, Parity: 1}, ss)
+	assert.Equal(t, []check.Group{{Num: 0, Missing: 1}}, res.Degraded)
+	assert.Equal(t, []check.Group{{Num: 1, Missing: 2}}, res.Unrecoverable)
+	assert.Equal(t, check.Unrecoverable, res.Status())
+
+	// invalid group size
+	_, err := check.Check{Data: 3, Parity: 1}.Run(ss, bytes.NewReader(idx.Bytes()))
+	assert.Error(t, err)
+}
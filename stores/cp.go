@@ -7,12 +7,17 @@ import (
 	"path/filepath"
 
 	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
 	"github.com/Roman2K/scat/procs"
 )
 
 type Cp Dir
 
-var _ Store = Cp{}
+var (
+	_ Store       = Cp{}
+	_ Deleter     = Cp{}
+	_ Quarantiner = Cp{}
+)
 
 func (cp Cp) Proc() procs.Proc {
 	return procs.InplaceFunc(cp.process)
@@ -44,6 +49,10 @@ func (cp Cp) Unproc() procs.Proc {
 }
 
 func (cp Cp) unprocess(c *scat.Chunk) (new *scat.Chunk, err error) {
+	if procs.Canceled(c) {
+		err = procs.ErrCanceled
+		return
+	}
 	path := Dir(cp).FullPath(c.Hash())
 	b, err := ioutil.ReadFile(path)
 	if os.IsNotExist(err) {
@@ -53,6 +62,27 @@ func (cp Cp) unprocess(c *scat.Chunk) (new *scat.Chunk, err error) {
 	return
 }
 
+func (cp Cp) Delete(hash checksum.Hash) (err error) {
+	err = os.Remove(Dir(cp).FullPath(hash))
+	if os.IsNotExist(err) {
+		err = procs.MissingDataError{err}
+	}
+	return
+}
+
+func (cp Cp) Quarantine(hash checksum.Hash) (err error) {
+	path := Dir(cp).QuarantinePath(hash)
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return
+	}
+	err = os.Rename(Dir(cp).FullPath(hash), path)
+	if os.IsNotExist(err) {
+		err = procs.MissingDataError{err}
+	}
+	return
+}
+
 func (cp Cp) Ls() ([]LsEntry, error) {
 	return Dir(cp).Ls(localLister{})
 }
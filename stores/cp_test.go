@@ -30,6 +30,8 @@ func (test dirStoreTest) run(t *testing.T) {
 	test.testMissingData(t)
 	test.testLs(t)
 	test.testLsMissingDir(t)
+	test.testDelete(t)
+	test.testQuarantine(t)
 }
 
 func (test dirStoreTest) testReadWrite(t *testing.T) {
@@ -207,3 +209,75 @@ func (test dirStoreTest) testLsMissingDir(t *testing.T) {
 		assert.True(t, os.IsNotExist(err))
 	}
 }
+
+func (test dirStoreTest) testDelete(t *testing.T) {
+	var (
+		hash = testutil.Hash1.Hash
+		hex  = testutil.Hash1.Hex
+	)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := test(stores.Dir{dir, stores.StrPart{2}})
+	del, ok := store.(stores.Deleter)
+	assert.True(t, ok)
+
+	// missing
+	err = del.Delete(hash)
+	missErr, ok := err.(procs.MissingDataError)
+	assert.True(t, ok)
+	assert.Error(t, missErr.Err)
+
+	// present
+	path := filepath.Join(dir, hex[:2], hex)
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(path, []byte("a"), 0644)
+	assert.NoError(t, err)
+	err = del.Delete(hash)
+	assert.NoError(t, err)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func (test dirStoreTest) testQuarantine(t *testing.T) {
+	var (
+		hash = testutil.Hash1.Hash
+		hex  = testutil.Hash1.Hex
+	)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := test(stores.Dir{dir, stores.StrPart{2}})
+	qr, ok := store.(stores.Quarantiner)
+	assert.True(t, ok)
+
+	// missing
+	err = qr.Quarantine(hash)
+	missErr, ok := err.(procs.MissingDataError)
+	assert.True(t, ok)
+	assert.Error(t, missErr.Err)
+
+	// present
+	path := filepath.Join(dir, hex[:2], hex)
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(path, []byte("a"), 0644)
+	assert.NoError(t, err)
+	err = qr.Quarantine(hash)
+	assert.NoError(t, err)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+	b, err := ioutil.ReadFile(filepath.Join(dir, "quarantine", "corrupt-"+hex))
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(b))
+
+	// no longer listed
+	ls, err := store.Ls()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(ls))
+}
@@ -1,18 +1,22 @@
 package stores
 
 import (
+	"archive/tar"
 	"bufio"
 	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
 	"github.com/Roman2K/scat/procs"
 )
 
@@ -31,14 +35,28 @@ type Dd struct {
 	Dir        Dir
 	Command    commandFunc
 	StrCommand strCommandFunc
+	Batch      int
 }
 
 type commandFunc func(string, ...string) *exec.Cmd
 type strCommandFunc func(env, string) *exec.Cmd
 
-var _ Store = Dd{}
+var (
+	_ Store       = Dd{}
+	_ Deleter     = Dd{}
+	_ Quarantiner = Dd{}
+	_ Batcher     = Dd{}
+)
+
+func (s Dd) WithBatch(size int) (Store, error) {
+	s.Batch = size
+	return s, nil
+}
 
 func (s Dd) Proc() procs.Proc {
+	if s.Batch > 0 {
+		return procs.NewBatch(s.Batch, batchDelay, s.processBatch)
+	}
 	return procs.CmdInFunc(s.process)
 }
 
@@ -69,6 +87,46 @@ func (s Dd) outCommand(path, ofArg string) *exec.Cmd {
 	return s.command("dd", ofArg, ddBsArg)
 }
 
+// Sent as a tar stream extracted under Dir.Path, nesting included.
+func (s Dd) processBatch(chunks []*scat.Chunk) (err error) {
+	var (
+		buf = &bytes.Buffer{}
+		tw  = tar.NewWriter(buf)
+		now = time.Now()
+	)
+	for _, c := range chunks {
+		b, err := c.Data().Bytes()
+		if err != nil {
+			return err
+		}
+		filename := fmt.Sprintf("%x", c.Hash())
+		err = tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     path.Join(append(s.Dir.Part.Split(filename), filename)...),
+			Mode:     0644,
+			Size:     int64(len(b)),
+			ModTime:  now,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(b)
+		if err != nil {
+			return err
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		return
+	}
+	cmd := s.strCommand(env{"ddproc_dir=" + s.Dir.Path},
+		`export ddproc_dir && tar -x -f - -C "$ddproc_dir"`,
+	)
+	cmd.Stdin = buf
+	_, err = cmd.Output()
+	return
+}
+
 func (s Dd) command(name string, args ...string) *exec.Cmd {
 	fn := exec.Command
 	if s.Command != nil {
@@ -110,6 +168,31 @@ func (s Dd) loadCmd(c *scat.Chunk) (*exec.Cmd, error) {
 	return s.command("dd", "if="+path, ddBsArg), nil
 }
 
+func (s Dd) Delete(hash checksum.Hash) (err error) {
+	path := s.Dir.FullPath(hash)
+	_, err = s.command("rm", path).Output()
+	return ddMissingErr(err)
+}
+
+func (s Dd) Quarantine(hash checksum.Hash) (err error) {
+	path := s.Dir.QuarantinePath(hash)
+	_, err = s.command("mkdir", "-p", filepath.Dir(path)).Output()
+	if err != nil {
+		return
+	}
+	_, err = s.command("mv", s.Dir.FullPath(hash), path).Output()
+	return ddMissingErr(err)
+}
+
+func ddMissingErr(err error) error {
+	if exit, ok := err.(*exec.ExitError); ok {
+		if noSuchFileRe.Match(exit.Stderr) {
+			return procs.MissingDataError{exit}
+		}
+	}
+	return err
+}
+
 func (s Dd) Ls() ([]LsEntry, error) {
 	return s.Dir.Ls(findDirLister(s.command))
 }
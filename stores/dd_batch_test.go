@@ -0,0 +1,49 @@
+package stores
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/testutil"
+	assert "github.com/stretchr/testify/require"
+)
+
+// One command per batch
+func TestDdBatchCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ncmds := 0
+	store := Dd{
+		Dir: Dir{dir, StrPart{2}},
+		StrCommand: func(env env, str string) *exec.Cmd {
+			ncmds++
+			cmd := exec.Command("sh", "-c", str)
+			cmd.Env = env
+			return cmd
+		},
+		Batch: 2,
+	}
+	proc := store.Proc()
+	chs := make([]<-chan procs.Res, len(testutil.Hashes))
+	for i, h := range testutil.Hashes {
+		c := scat.NewChunk(i, scat.BytesData(h.Hex[:i+1]))
+		c.SetHash(h.Hash)
+		chs[i] = proc.Process(c)
+	}
+	for _, ch := range chs {
+		_, err := testutil.ReadChunks(ch)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, ncmds)
+	for i, h := range testutil.Hashes {
+		b, err := ioutil.ReadFile(filepath.Join(dir, h.Hex[:2], h.Hex))
+		assert.NoError(t, err)
+		assert.Equal(t, h.Hex[:i+1], string(b))
+	}
+}
@@ -11,3 +11,9 @@ func TestDd(t *testing.T) {
 		return stores.Dd{Dir: dir}
 	}).run(t)
 }
+
+func TestDdBatch(t *testing.T) {
+	dirStoreTest(func(dir stores.Dir) stores.Store {
+		return stores.Dd{Dir: dir, Batch: 2}
+	}).run(t)
+}
@@ -0,0 +1,18 @@
+package stores
+
+import (
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/procs"
+)
+
+// Deletes chunks by hash. Already missing data isn't an error, as with rm -f,
+// so that deleting is idempotent and a chain may try stores not having a copy.
+func NewDeleteProc(del Deleter) procs.Proc {
+	return procs.InplaceFunc(func(c *scat.Chunk) error {
+		err := del.Delete(c.Hash())
+		if _, ok := err.(procs.MissingDataError); ok {
+			err = nil
+		}
+		return err
+	})
+}
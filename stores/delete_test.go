@@ -0,0 +1,48 @@
+package stores_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/stores"
+	"github.com/Roman2K/scat/testutil"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestDeleteProc(t *testing.T) {
+	var (
+		hash = testutil.Hash1.Hash
+	)
+
+	mem := stores.NewMem()
+	mem.Set(hash, []byte("xxx"))
+	proc := stores.NewDeleteProc(mem)
+
+	c := scat.NewChunk(0, nil)
+	c.SetHash(hash)
+	chunks, err := testutil.ReadChunks(proc.Process(c))
+	assert.NoError(t, err)
+	assert.Equal(t, []*scat.Chunk{c}, chunks)
+	assert.Equal(t, 0, len(mem.Hashes()))
+
+	// missing
+	chunks, err = testutil.ReadChunks(proc.Process(c))
+	assert.NoError(t, err)
+	assert.Equal(t, []*scat.Chunk{c}, chunks)
+
+	// other err
+	someErr := errors.New("some err")
+	proc = stores.NewDeleteProc(errDeleter{someErr})
+	_, err = testutil.ReadChunks(proc.Process(c))
+	assert.Equal(t, someErr, err)
+}
+
+type errDeleter struct {
+	err error
+}
+
+func (d errDeleter) Delete(checksum.Hash) error {
+	return d.err
+}
@@ -21,6 +21,14 @@ func (d Dir) FullPath(hash checksum.Hash) string {
 	return filepath.Join(parts...)
 }
 
+// Quarantined files are prefixed so as to no longer be listed by Ls, whatever
+// the depth of the listing.
+func (d Dir) QuarantinePath(hash checksum.Hash) string {
+	return filepath.Join(d.Path, quarantineDir, fmt.Sprintf("corrupt-%x", hash))
+}
+
+const quarantineDir = "quarantine"
+
 type DirLister interface {
 	Ls(dir string, depth int) <-chan DirLsRes
 }
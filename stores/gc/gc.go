@@ -0,0 +1,76 @@
+package gc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/concur"
+	"github.com/Roman2K/scat/index"
+	"github.com/Roman2K/scat/stores"
+)
+
+var ErrNoLive = errors.New("no live chunks, refusing to delete everything")
+
+type Live map[checksum.Hash]struct{}
+
+func (live Live) AddIndex(r io.Reader) error {
+	scan := index.NewScanner(0, r)
+	for scan.Next() {
+		live[scan.Chunk().Hash()] = struct{}{}
+	}
+	return scan.Err()
+}
+
+type Report struct {
+	Id    interface{}
+	Count int
+	Size  int64
+}
+
+func Collect(live Live, ss []stores.NamedStore, dryRun bool) (
+	reports []Report, err error,
+) {
+	if len(live) == 0 {
+		err = ErrNoLive
+		return
+	}
+	dels := make([]stores.Deleter, len(ss))
+	for i, s := range ss {
+		del, ok := s.Store.(stores.Deleter)
+		if !ok && !dryRun {
+			err = fmt.Errorf("store %v: %v", s.Id(), stores.ErrNoDelete)
+			return
+		}
+		dels[i] = del
+	}
+	reports = make([]Report, len(ss))
+	fns := make(concur.Funcs, len(ss))
+	for i := range ss {
+		s, del, rep := ss[i], dels[i], &reports[i]
+		rep.Id = s.Id()
+		fns[i] = func() error {
+			ls, err := s.Ls()
+			if err != nil {
+				return err
+			}
+			for _, e := range ls {
+				if _, ok := live[e.Hash]; ok {
+					continue
+				}
+				if !dryRun {
+					err = del.Delete(e.Hash)
+					if err != nil {
+						return err
+					}
+				}
+				rep.Count++
+				rep.Size += e.Size
+			}
+			return nil
+		}
+	}
+	err = fns.FirstErr()
+	return
+}
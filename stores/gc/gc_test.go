@@ -0,0 +1,85 @@
+package gc_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/index"
+	"github.com/Roman2K/scat/stores"
+	"github.com/Roman2K/scat/stores/gc"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestCollect(t *testing.T) {
+	var (
+		hash1 = checksum.SumBytes([]byte("a"))
+		hash2 = checksum.SumBytes([]byte("b"))
+		hash3 = checksum.SumBytes([]byte("c"))
+	)
+
+	mem1 := stores.NewMem()
+	mem1.Set(hash1, []byte("a"))
+	mem1.Set(hash2, []byte("bb"))
+	mem2 := stores.NewMem()
+	mem2.Set(hash2, []byte("bb"))
+	mem2.Set(hash3, []byte("ccc"))
+	ss := []stores.NamedStore{
+		{"mem1", mem1},
+		{"mem2", mem2},
+	}
+
+	// no live chunks
+	_, err := gc.Collect(gc.Live{}, ss, true)
+	assert.Equal(t, gc.ErrNoLive, err)
+
+	live := gc.Live{}
+	buf := &bytes.Buffer{}
+	index.Write(buf, hash1, 1)
+	index.Write(buf, hash3, 3)
+	err = live.AddIndex(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(live))
+
+	// dry run
+	reports, err := gc.Collect(live, ss, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []gc.Report{
+		{Id: "mem1", Count: 1, Size: 2},
+		{Id: "mem2", Count: 1, Size: 2},
+	}, reports)
+	assert.Equal(t, 2, len(mem1.Hashes()))
+	assert.Equal(t, 2, len(mem2.Hashes()))
+
+	// delete
+	reports, err = gc.Collect(live, ss, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(reports))
+	assert.Equal(t, []checksum.Hash{hash1}, mem1.Hashes())
+	assert.Equal(t, []checksum.Hash{hash3}, mem2.Hashes())
+
+	// nothing left to collect
+	reports, err = gc.Collect(live, ss, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []gc.Report{
+		{Id: "mem1"},
+		{Id: "mem2"},
+	}, reports)
+}
+
+func TestCollectNoDeleter(t *testing.T) {
+	live := gc.Live{checksum.SumBytes([]byte("a")): {}}
+	ss := []stores.NamedStore{{"ls", lsOnly{}}}
+	_, err := gc.Collect(live, ss, true)
+	assert.NoError(t, err)
+	_, err = gc.Collect(live, ss, false)
+	assert.Error(t, err)
+}
+
+type lsOnly struct {
+	stores.Store
+}
+
+func (lsOnly) Ls() ([]stores.LsEntry, error) {
+	return nil, nil
+}
@@ -0,0 +1,385 @@
+package stores
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+)
+
+// Files nested like Dir under URL, uploaded with PUT and downloaded with GET.
+//
+// With WebDav, missing parent collections get created with MKCOL, files are
+// listed with PROPFIND and quarantined with MOVE. Otherwise, GET of URL must
+// return a JSON listing of all files: [{"name": <path under URL>, "size":
+// <bytes>}, ...], and quarantining copies then deletes.
+type Http struct {
+	URL    string
+	Part   StrPart
+	WebDav bool
+	User   string
+	Pass   string
+	Client *http.Client // nil for http.DefaultClient
+}
+
+var (
+	_ Store       = Http{}
+	_ Deleter     = Http{}
+	_ Quarantiner = Http{}
+)
+
+// Basic auth credentials are read from SCAT_HTTP_USER and SCAT_HTTP_PASSWORD,
+// unless included in url.
+func NewHttp(url string, part StrPart, webdav bool) Http {
+	return Http{
+		URL:    strings.TrimSuffix(url, "/"),
+		Part:   part,
+		WebDav: webdav,
+		User:   os.Getenv("SCAT_HTTP_USER"),
+		Pass:   os.Getenv("SCAT_HTTP_PASSWORD"),
+	}
+}
+
+func (h Http) path(hash checksum.Hash) string {
+	return filepath.ToSlash(Dir{Part: h.Part}.FullPath(hash))
+}
+
+func (h Http) quarantinePath(hash checksum.Hash) string {
+	return filepath.ToSlash(Dir{}.QuarantinePath(hash))
+}
+
+func (h Http) Proc() procs.Proc {
+	return procs.InplaceFunc(h.process)
+}
+
+func (h Http) process(c *scat.Chunk) (err error) {
+	b, err := c.Data().Bytes()
+	if err != nil {
+		return
+	}
+	return h.put(h.path(c.Hash()), b)
+}
+
+func (h Http) put(p string, b []byte) (err error) {
+	_, err = h.do("PUT", p, nil, b)
+	if !h.WebDav {
+		return
+	}
+	// Missing parent: 409 as per RFC 4918, 404 for some servers
+	switch httpStatus(err) {
+	case http.StatusConflict, http.StatusNotFound:
+		err = h.mkcols(path.Dir(p))
+		if err != nil {
+			return
+		}
+		_, err = h.do("PUT", p, nil, b)
+	}
+	return
+}
+
+// Creates the collection at dir and its parents, existing ones included.
+func (h Http) mkcols(dir string) (err error) {
+	if dir == "." || dir == "/" {
+		return
+	}
+	err = h.mkcols(path.Dir(dir))
+	if err != nil {
+		return
+	}
+	_, err = h.do("MKCOL", dir+"/", nil, nil)
+	if httpStatus(err) == http.StatusMethodNotAllowed {
+		// Already exists
+		err = nil
+	}
+	return
+}
+
+func (h Http) Unproc() procs.Proc {
+	return procs.ChunkFunc(h.unprocess)
+}
+
+func (h Http) unprocess(c *scat.Chunk) (new *scat.Chunk, err error) {
+	b, _, err := h.get(c)
+	new = c.WithData(scat.BytesData(b))
+	return
+}
+
+// GETs c, the request being canceled along with it: see procs.WithDone.
+func (h Http) get(c *scat.Chunk) (out []byte, hdr http.Header, err error) {
+	ctx, cancel := chunkContext(c)
+	defer cancel()
+	out, hdr, err = h.doCtx(ctx, "GET", h.path(c.Hash()), nil, nil)
+	if err != nil && ctx.Err() != nil {
+		err = procs.ErrCanceled
+	}
+	return
+}
+
+func chunkContext(c *scat.Chunk) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if done := procs.Done(c); done != nil {
+		go func() {
+			select {
+			case <-done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return ctx, cancel
+}
+
+func (h Http) Delete(hash checksum.Hash) (err error) {
+	_, err = h.do("DELETE", h.path(hash), nil, nil)
+	return
+}
+
+func (h Http) Quarantine(hash checksum.Hash) (err error) {
+	dst := h.quarantinePath(hash)
+	if !h.WebDav {
+		var b []byte
+		b, err = h.do("GET", h.path(hash), nil, nil)
+		if err != nil {
+			return
+		}
+		err = h.put(dst, b)
+		if err != nil {
+			return
+		}
+		return h.Delete(hash)
+	}
+	err = h.mkcols(path.Dir(dst))
+	if err != nil {
+		return
+	}
+	dstUrl, err := url.Parse(h.url(dst))
+	if err != nil {
+		return
+	}
+	dstUrl.User = nil
+	hdr := map[string]string{"Destination": dstUrl.String(), "Overwrite": "T"}
+	_, err = h.do("MOVE", h.path(hash), hdr, nil)
+	if err != nil && httpStatus(err) != http.StatusNotFound {
+		// Some servers respond otherwise for a missing source
+		_, herr := h.do("HEAD", h.path(hash), nil, nil)
+		if httpStatus(herr) == http.StatusNotFound {
+			err = herr
+		}
+	}
+	return
+}
+
+func (h Http) Ls() (entries []LsEntry, err error) {
+	var files []httpFile
+	if h.WebDav {
+		files, err = h.propfind("", len(h.Part)+1)
+	} else {
+		files, err = h.lsJson()
+	}
+	if err != nil {
+		return
+	}
+	entries = make([]LsEntry, 0, len(files))
+	var (
+		buf   = make([]byte, checksum.Size)
+		entry LsEntry
+	)
+	for _, f := range files {
+		n, err := fmt.Sscanf(path.Base(f.Name), "%x", &buf)
+		if err != nil || n != 1 {
+			continue
+		}
+		err = entry.Hash.LoadSlice(buf)
+		if err != nil {
+			continue
+		}
+		entry.Size = f.Size
+		entries = append(entries, entry)
+	}
+	return
+}
+
+type httpFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+func (h Http) lsJson() (files []httpFile, err error) {
+	hdr := map[string]string{"Accept": "application/json"}
+	b, err := h.do("GET", "", hdr, nil)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(b, &files)
+	return
+}
+
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Prop struct {
+				Length       int64 `xml:"DAV: getcontentlength"`
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+			} `xml:"DAV: prop"`
+			Status string `xml:"DAV: status"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+const davPropfind = `<?xml version="1.0" encoding="utf-8"?>
+<propfind xmlns="DAV:">
+  <prop><resourcetype/><getcontentlength/></prop>
+</propfind>`
+
+// Lists files depth levels below dir, one level per request, as servers
+// commonly refuse "Depth: infinity".
+func (h Http) propfind(dir string, depth int) (files []httpFile, err error) {
+	hdr := map[string]string{"Depth": "1", "Content-Type": "application/xml"}
+	b, err := h.do("PROPFIND", dir+"/", hdr, []byte(davPropfind))
+	if err != nil {
+		return
+	}
+	ms := davMultistatus{}
+	err = xml.Unmarshal(b, &ms)
+	if err != nil {
+		return
+	}
+	self, err := url.Parse(h.url(dir + "/"))
+	if err != nil {
+		return
+	}
+	for _, res := range ms.Responses {
+		href, err := url.Parse(res.Href)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSuffix(href.Path, "/") ==
+			strings.TrimSuffix(self.Path, "/") {
+			continue
+		}
+		var (
+			name  = path.Join(dir, path.Base(href.Path))
+			isDir bool
+			size  int64
+		)
+		for _, ps := range res.Propstat {
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			isDir = isDir || ps.Prop.ResourceType.Collection != nil
+			size = ps.Prop.Length
+		}
+		switch {
+		case isDir && depth > 1:
+			sub, err := h.propfind(name, depth-1)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, sub...)
+		case !isDir && depth == 1:
+			files = append(files, httpFile{name, size})
+		}
+	}
+	return
+}
+
+type httpError struct {
+	Method string
+	URL    string
+	Status int
+}
+
+func (e httpError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s",
+		e.Method, e.URL, e.Status, http.StatusText(e.Status),
+	)
+}
+
+func httpStatus(err error) int {
+	if e, ok := err.(procs.MissingDataError); ok {
+		err = e.Err
+	}
+	if e, ok := err.(httpError); ok {
+		return e.Status
+	}
+	return 0
+}
+
+func (h Http) url(p string) string {
+	u := h.URL
+	if p != "" {
+		u += (&url.URL{Path: "/" + strings.TrimPrefix(p, "/")}).EscapedPath()
+	}
+	return u
+}
+
+func (h Http) do(method, p string, hdr map[string]string, body []byte) (
+	out []byte, err error,
+) {
+	out, _, err = h.doHdr(method, p, hdr, body)
+	return
+}
+
+func (h Http) doHdr(method, p string, hdr map[string]string, body []byte) (
+	out []byte, respHdr http.Header, err error,
+) {
+	return h.doCtx(context.Background(), method, p, hdr, body)
+}
+
+func (h Http) doCtx(ctx context.Context, method, p string,
+	hdr map[string]string, body []byte,
+) (out []byte, respHdr http.Header, err error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, h.url(p), r)
+	if err != nil {
+		return
+	}
+	for k, v := range hdr {
+		req.Header.Set(k, v)
+	}
+	if h.User != "" {
+		req.SetBasicAuth(h.User, h.Pass)
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	respHdr = resp.Header
+	out, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode/100 == 2 {
+		return
+	}
+	out = nil
+	err = httpError{method, req.URL.Redacted(), resp.StatusCode}
+	if resp.StatusCode == http.StatusNotFound {
+		err = procs.MissingDataError{err}
+	}
+	return
+}
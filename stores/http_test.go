@@ -0,0 +1,193 @@
+package stores
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/testutil"
+	assert "github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+func TestHttpWebDav(t *testing.T) {
+	dav := &webdav.Handler{
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	}
+	ts := httptest.NewServer(withBasicAuth("user", "pass", dav))
+	defer ts.Close()
+	t.Setenv("SCAT_HTTP_USER", "user")
+	t.Setenv("SCAT_HTTP_PASSWORD", "pass")
+
+	h := NewHttp(ts.URL+"/", StrPart{2, 1}, true)
+	testHttp(t, h, func(name string) (string, bool) {
+		f, err := dav.FileSystem.OpenFile(nil, name, os.O_RDONLY, 0)
+		if err != nil {
+			return "", false
+		}
+		defer f.Close()
+		b, err := ioutil.ReadAll(f)
+		assert.NoError(t, err)
+		return string(b), true
+	})
+
+	// unauthorized
+	h.Pass = "bad"
+	_, err := h.Ls()
+	assert.Error(t, err)
+	_, ok := err.(procs.MissingDataError)
+	assert.False(t, ok)
+}
+
+func TestHttpJson(t *testing.T) {
+	fake := &fakeHttp{files: make(map[string][]byte)}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	h := NewHttp(ts.URL+"/some/dir", StrPart{2}, false)
+	testHttp(t, h, func(name string) (string, bool) {
+		b, ok := fake.files["/some/dir"+name]
+		return string(b), ok
+	})
+}
+
+type httpStore interface {
+	Store
+	Deleter
+	Quarantiner
+	path(checksum.Hash) string
+}
+
+func testHttp(t *testing.T, h httpStore, read func(string) (string, bool)) {
+	var (
+		hash1 = testutil.Hash1.Hash
+		hash2 = testutil.Hashes[1].Hash
+		path1 = "/" + h.path(hash1)
+	)
+	put := func(hash checksum.Hash, data string) {
+		c := scat.NewChunk(0, scat.BytesData(data))
+		c.SetHash(hash)
+		_, err := testutil.ReadChunks(h.Proc().Process(c))
+		assert.NoError(t, err)
+	}
+	get := func(hash checksum.Hash) (string, error) {
+		c := scat.NewChunk(0, nil)
+		c.SetHash(hash)
+		chunks, err := testutil.ReadChunks(h.Unproc().Process(c))
+		if err != nil {
+			return "", err
+		}
+		b, err := chunks[0].Data().Bytes()
+		return string(b), err
+	}
+	ls := func() []LsEntry {
+		ls, err := h.Ls()
+		assert.NoError(t, err)
+		sort.Slice(ls, func(i, j int) bool {
+			return ls[i].Size > ls[j].Size
+		})
+		return ls
+	}
+	assertMissing := func(err error) {
+		_, ok := err.(procs.MissingDataError)
+		assert.True(t, ok, "not a missing data error: %v", err)
+	}
+
+	// write, read
+	put(hash1, "abc")
+	put(hash2, "de")
+	data, ok := read(path1)
+	assert.True(t, ok)
+	assert.Equal(t, "abc", data)
+	data, err := get(hash1)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", data)
+
+	// ls
+	assert.Equal(t, []LsEntry{{hash1, 3}, {hash2, 2}}, ls())
+
+	// quarantine
+	err = h.Quarantine(hash1)
+	assert.NoError(t, err)
+	_, ok = read(path1)
+	assert.False(t, ok)
+	data, ok = read("/quarantine/corrupt-" + testutil.Hash1.Hex)
+	assert.True(t, ok)
+	assert.Equal(t, "abc", data)
+	assert.Equal(t, []LsEntry{{hash2, 2}}, ls())
+
+	// missing
+	_, err = get(hash1)
+	assertMissing(err)
+	err = h.Quarantine(hash1)
+	assertMissing(err)
+	err = h.Delete(hash1)
+	assertMissing(err)
+
+	// delete
+	err = h.Delete(hash2)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(ls()))
+}
+
+func withBasicAuth(user, pass string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok || u != user || p != pass {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Plain file server with a JSON listing at the root
+type fakeHttp struct {
+	files map[string][]byte
+	mu    sync.Mutex
+}
+
+func (f *fakeHttp) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	const root = "/some/dir"
+	p := r.URL.Path
+	switch {
+	case p == root && r.Method == "GET":
+		list := []httpFile{}
+		for name, b := range f.files {
+			name = strings.TrimPrefix(name, root+"/")
+			list = append(list, httpFile{name, int64(len(b))})
+		}
+		json.NewEncoder(w).Encode(list)
+	case r.Method == "PUT":
+		b, _ := ioutil.ReadAll(r.Body)
+		f.files[p] = b
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "GET":
+		b, ok := f.files[p]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(b)
+	case r.Method == "DELETE":
+		if _, ok := f.files[p]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		delete(f.files, p)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,367 @@
+package stores
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+)
+
+// Listers able to list from a possibly stale cache, refreshed in the
+// background. Changes found by the refresh are passed to fn.
+type CachedLister interface {
+	Lister
+	LsCached(fn func(added, removed []LsEntry)) ([]LsEntry, error)
+}
+
+// Caches the listing of Store on disk, kept up to date with the chunks
+// written to, deleted from and found missing from Store.
+//
+// A cached listing younger than TTL is used as is. An older one is used all
+// the same by LsCached, while refreshed in the background, and replaced by
+// Ls. With a TTL of 0, the cache is always replaced before use.
+type LsCache struct {
+	Store
+	Path string
+	TTL  time.Duration
+
+	mu      sync.Mutex
+	lsTime  time.Time
+	entries map[checksum.Hash]int64 // nil until loaded
+	journal map[checksum.Hash]int64 // during refreshes, -1 for removals
+	pending []LsEntry               // changes not appended yet, -1 for removals
+}
+
+var (
+	_ CachedLister = &LsCache{}
+	_ Store        = &LsCache{}
+	_ Deleter      = &LsCache{}
+	_ Quarantiner  = &LsCache{}
+)
+
+func lsCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	return filepath.Join(dir, "scat", "ls"), err
+}
+
+// Caches under the user's cache directory, with key identifying store
+// across runs.
+func NewLsCache(store Store, key string, ttl time.Duration) (
+	c *LsCache, err error,
+) {
+	dir, err := lsCacheDir()
+	if err != nil {
+		return
+	}
+	c = &LsCache{
+		Store: store,
+		Path:  filepath.Join(dir, fmt.Sprintf("%x", sha256.Sum256([]byte(key)))),
+		TTL:   ttl,
+	}
+	return
+}
+
+func (c *LsCache) Ls() (entries []LsEntry, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fresh() {
+		return c.list(), nil
+	}
+	return c.refresh()
+}
+
+func (c *LsCache) LsCached(fn func(added, removed []LsEntry)) (
+	entries []LsEntry, err error,
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fresh() {
+		return c.list(), nil
+	}
+	if c.TTL == 0 || c.entries == nil || c.journal != nil {
+		return c.refresh()
+	}
+	c.journal = make(map[checksum.Hash]int64)
+	go func() {
+		added, removed, err := c.refreshBg()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "lscache: refresh error: %v\n", err)
+			return
+		}
+		fn(added, removed)
+	}()
+	return c.list(), nil
+}
+
+func (c *LsCache) fresh() bool {
+	c.load()
+	return c.entries != nil && c.TTL > 0 && time.Since(c.lsTime) < c.TTL
+}
+
+func (c *LsCache) list() (entries []LsEntry) {
+	entries = make([]LsEntry, 0, len(c.entries))
+	for hash, size := range c.entries {
+		entries = append(entries, LsEntry{hash, size})
+	}
+	return
+}
+
+func (c *LsCache) refresh() (entries []LsEntry, err error) {
+	now := time.Now()
+	entries, err = c.Store.Ls()
+	if err != nil {
+		return
+	}
+	c.entries = make(map[checksum.Hash]int64, len(entries))
+	for _, e := range entries {
+		c.entries[e.Hash] = e.Size
+	}
+	c.lsTime = now
+	err = c.save()
+	return
+}
+
+// Replaces the listing, then reapplies the changes observed meanwhile.
+func (c *LsCache) refreshBg() (added, removed []LsEntry, err error) {
+	now := time.Now()
+	ls, err := c.Store.Ls()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	journal := c.journal
+	c.journal = nil
+	if err != nil {
+		return
+	}
+	entries := make(map[checksum.Hash]int64, len(ls))
+	for _, e := range ls {
+		entries[e.Hash] = e.Size
+	}
+	for hash, size := range journal {
+		if size < 0 {
+			delete(entries, hash)
+		} else {
+			entries[hash] = size
+		}
+	}
+	for hash, size := range entries {
+		if _, ok := c.entries[hash]; !ok {
+			added = append(added, LsEntry{hash, size})
+		}
+	}
+	for hash, size := range c.entries {
+		if _, ok := entries[hash]; !ok {
+			removed = append(removed, LsEntry{hash, size})
+		}
+	}
+	c.entries = entries
+	c.lsTime = now
+	err = c.save()
+	return
+}
+
+func (c *LsCache) set(hash checksum.Hash, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	if c.entries == nil {
+		return
+	}
+	if size < 0 {
+		delete(c.entries, hash)
+	} else {
+		c.entries[hash] = size
+	}
+	if c.journal != nil {
+		c.journal[hash] = size
+	}
+	c.pending = append(c.pending, LsEntry{hash, size})
+}
+
+func (c *LsCache) Proc() procs.Proc {
+	return lsCacheProc{c, c.Store.Proc(), true}
+}
+
+func (c *LsCache) Unproc() procs.Proc {
+	return lsCacheProc{c, c.Store.Unproc(), false}
+}
+
+type lsCacheProc struct {
+	cache *LsCache
+	proc  procs.Proc
+	write bool
+}
+
+func (p lsCacheProc) Process(chunk *scat.Chunk) <-chan procs.Res {
+	onEnd := func(err error) {
+		if _, ok := err.(procs.MissingDataError); ok {
+			p.cache.set(chunk.Hash(), -1)
+			return
+		}
+		if sz, ok := chunk.Data().(scat.Sizer); ok && p.write && err == nil {
+			p.cache.set(chunk.Hash(), int64(sz.Size()))
+		}
+	}
+	return procs.OnEnd{p.proc, onEnd}.Process(chunk)
+}
+
+func (p lsCacheProc) Finish() (err error) {
+	err = p.proc.Finish()
+	c := p.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if aerr := c.appendPending(); err == nil {
+		err = aerr
+	}
+	return
+}
+
+func (c *LsCache) Delete(hash checksum.Hash) (err error) {
+	del, ok := c.Store.(Deleter)
+	if !ok {
+		return ErrNoDelete
+	}
+	return c.removing(hash, del.Delete(hash))
+}
+
+func (c *LsCache) Quarantine(hash checksum.Hash) (err error) {
+	quar, ok := c.Store.(Quarantiner)
+	if !ok {
+		return ErrNoQuarantine
+	}
+	return c.removing(hash, quar.Quarantine(hash))
+}
+
+func (c *LsCache) removing(hash checksum.Hash, err error) error {
+	_, missing := err.(procs.MissingDataError)
+	if err != nil && !missing {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	if c.entries == nil {
+		return err
+	}
+	delete(c.entries, hash)
+	if c.journal != nil {
+		c.journal[hash] = -1
+	}
+	c.pending = append(c.pending, LsEntry{hash, -1})
+	if aerr := c.appendPending(); err == nil {
+		err = aerr
+	}
+	return err
+}
+
+// Rather than rewriting the whole cache for every change, appends changes to
+// it, applied on load. Only refreshes rewrite it.
+func (c *LsCache) appendPending() (err error) {
+	if len(c.pending) == 0 {
+		return
+	}
+	f, err := os.OpenFile(c.Path, os.O_WRONLY|os.O_APPEND, 0)
+	if os.IsNotExist(err) {
+		return c.save()
+	}
+	if err != nil {
+		return
+	}
+	w := bufio.NewWriter(f)
+	for _, e := range c.pending {
+		fmt.Fprintf(w, "%x %d\n", e.Hash, e.Size)
+	}
+	err = w.Flush()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+	c.pending = nil
+	return
+}
+
+// Missing or unreadable caches are ignored, to be replaced.
+func (c *LsCache) load() {
+	if c.entries != nil {
+		return
+	}
+	f, err := os.Open(c.Path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var (
+		scan    = bufio.NewScanner(f)
+		entries = make(map[checksum.Hash]int64)
+		t       int64
+		buf     = make([]byte, checksum.Size)
+		hash    checksum.Hash
+		size    int64
+	)
+	if !scan.Scan() {
+		return
+	}
+	if _, err := fmt.Sscanf(scan.Text(), "%d", &t); err != nil {
+		return
+	}
+	for scan.Scan() {
+		n, err := fmt.Sscanf(scan.Text(), "%x %d", &buf, &size)
+		if err != nil || n != 2 || hash.LoadSlice(buf) != nil {
+			return
+		}
+		if size < 0 {
+			delete(entries, hash)
+			continue
+		}
+		entries[hash] = size
+	}
+	if scan.Err() != nil {
+		return
+	}
+	c.entries, c.lsTime = entries, time.Unix(t, 0)
+}
+
+// Written to a temporary file first, so as not to leave a truncated cache
+// behind.
+func (c *LsCache) save() (err error) {
+	if c.entries == nil {
+		return
+	}
+	dir := filepath.Dir(c.Path)
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return
+	}
+	f, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return
+	}
+	defer os.Remove(f.Name())
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "%d\n", c.lsTime.Unix())
+	for hash, size := range c.entries {
+		fmt.Fprintf(w, "%x %d\n", hash, size)
+	}
+	err = w.Flush()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+	err = os.Rename(f.Name(), c.Path)
+	if err != nil {
+		return
+	}
+	c.pending = nil
+	return
+}
@@ -0,0 +1,171 @@
+package stores
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/stores/copies"
+	"github.com/Roman2K/scat/stores/quota"
+	"github.com/Roman2K/scat/testutil"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestLsCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	var (
+		path  = filepath.Join(dir, "ls")
+		hash1 = testutil.Hash1.Hash
+		hash2 = testutil.Hashes[1].Hash
+		hash3 = checksum.SumBytes([]byte("f"))
+	)
+	mem := &gatedStore{Mem: NewMem()}
+	mem.Set(hash1, []byte("abc"))
+	newCache := func(ttl time.Duration) *LsCache {
+		return &LsCache{Store: mem, Path: path, TTL: ttl}
+	}
+	ls := func(c *LsCache) []LsEntry {
+		ls, err := c.Ls()
+		assert.NoError(t, err)
+		return sortLs(ls)
+	}
+
+	// listed once, then from the cache
+	c := newCache(time.Hour)
+	assert.Equal(t, []LsEntry{{hash1, 3}}, ls(c))
+	assert.Equal(t, 1, mem.nls)
+	mem.Set(hash2, []byte("de"))
+	assert.Equal(t, []LsEntry{{hash1, 3}}, ls(newCache(time.Hour)))
+	assert.Equal(t, 1, mem.nls)
+
+	// writes and misses
+	put := c.Proc()
+	err = procs.Process(put, chunkWithHash(hash3, "f"))
+	assert.NoError(t, err)
+	err = procs.Process(c.Unproc(), chunkWithHash(hash1, ""))
+	assert.NoError(t, err)
+	mem.Delete(hash1)
+	_, err = testutil.ReadChunks(c.Unproc().Process(chunkWithHash(hash1, "")))
+	assert.Error(t, err)
+	assert.NoError(t, put.Finish())
+	assert.Equal(t, []LsEntry{{hash3, 1}}, ls(newCache(time.Hour)))
+	assert.Equal(t, 1, mem.nls)
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, strings.Count(string(b), "\n")) // appended to
+
+	// stale: used, then refreshed in the background
+	mem.Set(hash1, []byte("abc"))
+	mem.gate = make(chan struct{})
+	c = newCache(time.Nanosecond)
+	type change struct{ added, removed []LsEntry }
+	changes := make(chan change)
+	cached, err := c.LsCached(func(added, removed []LsEntry) {
+		changes <- change{sortLs(added), sortLs(removed)}
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []LsEntry{{hash3, 1}}, cached)
+	mem.Delete(hash2)
+	err = procs.Process(c.Proc(), chunkWithHash(hash2, "xy"))
+	assert.NoError(t, err)
+	close(mem.gate)
+	ch := <-changes
+	assert.Equal(t, []LsEntry{{hash1, 3}}, ch.added)
+	assert.Nil(t, ch.removed)
+	assert.Equal(t, 2, mem.nls)
+	c.TTL = time.Hour
+	assert.Equal(t, []LsEntry{{hash1, 3}, {hash2, 2}, {hash3, 1}}, ls(c))
+	assert.Equal(t, 2, mem.nls)
+	b, err = ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, strings.Count(string(b), "\n")) // compacted
+
+	// no TTL: always listed
+	mem.gate = nil
+	mem.Delete(hash3)
+	cached, err = newCache(0).LsCached(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []LsEntry{{hash1, 3}, {hash2, 2}}, sortLs(cached))
+	assert.Equal(t, 3, mem.nls)
+
+	// deletion: only the entry dropped, on disk too
+	err = c.Delete(hash1)
+	assert.NoError(t, err)
+	assert.Equal(t, []LsEntry{{hash2, 2}, {hash3, 1}}, ls(c))
+	assert.Equal(t, []LsEntry{{hash2, 2}}, ls(newCache(time.Hour)))
+	assert.Equal(t, 3, mem.nls)
+}
+
+func TestLsCacheMultiLister(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	var (
+		hash1 = testutil.Hash1.Hash
+		hash2 = testutil.Hashes[1].Hash
+	)
+	mem := &gatedStore{Mem: NewMem()}
+	mem.Set(hash1, []byte("abc"))
+	c := &LsCache{Store: mem, Path: filepath.Join(dir, "ls"), TTL: time.Hour}
+	_, err = c.Ls()
+	assert.NoError(t, err)
+	mem.Delete(hash1)
+	mem.Set(hash2, []byte("de"))
+
+	c.TTL = time.Nanosecond
+	mem.gate = make(chan struct{})
+	cp := Copier{"a", c, c.Proc()}
+	reg := copies.NewReg()
+	qman := quota.NewMan()
+	qman.AddResQuota(cp, 4)
+	err = MultiLister{cp}.AddEntriesTo([]LsEntryAdder{
+		CopiesEntryAdder{Reg: reg},
+		QuotaEntryAdder{Qman: qman},
+	})
+	assert.NoError(t, err)
+	assert.True(t, reg.List(hash1).Contains(cp))
+	assert.False(t, reg.List(hash2).Contains(cp))
+	assert.Equal(t, 0, len(qman.Resources(2)))
+	close(mem.gate)
+	for reg.List(hash1).Contains(cp) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, reg.List(hash2).Contains(cp))
+	assert.Equal(t, 1, len(qman.Resources(2)))
+}
+
+type gatedStore struct {
+	*Mem
+	gate chan struct{}
+	nls  int
+}
+
+func (s *gatedStore) Ls() ([]LsEntry, error) {
+	s.nls++
+	if s.gate != nil {
+		<-s.gate
+	}
+	return s.Mem.Ls()
+}
+
+func chunkWithHash(hash checksum.Hash, data string) *scat.Chunk {
+	c := scat.NewChunk(0, scat.BytesData(data))
+	c.SetHash(hash)
+	return c
+}
+
+func sortLs(ls []LsEntry) []LsEntry {
+	sort.Slice(ls, func(i, j int) bool {
+		return ls[i].Size > ls[j].Size
+	})
+	return ls
+}
@@ -10,17 +10,23 @@ import (
 )
 
 type Mem struct {
-	data   memMap
-	dataMu sync.RWMutex
+	data       memMap
+	quarantine memMap
+	dataMu     sync.RWMutex
 }
 
 type memMap map[checksum.Hash][]byte
 
-var _ Store = (*Mem)(nil)
+var (
+	_ Store       = (*Mem)(nil)
+	_ Deleter     = (*Mem)(nil)
+	_ Quarantiner = (*Mem)(nil)
+)
 
 func NewMem() *Mem {
 	return &Mem{
-		data: make(memMap),
+		data:       make(memMap),
+		quarantine: make(memMap),
 	}
 }
 
@@ -42,6 +48,9 @@ func (s *Mem) Unproc() procs.Proc {
 }
 
 func (s *Mem) unprocess(c *scat.Chunk) (*scat.Chunk, error) {
+	if procs.Canceled(c) {
+		return nil, procs.ErrCanceled
+	}
 	s.dataMu.RLock()
 	data, ok := s.data[c.Hash()]
 	s.dataMu.RUnlock()
@@ -75,10 +84,36 @@ func (s *Mem) Get(hash checksum.Hash) []byte {
 	return s.data[hash]
 }
 
-func (s *Mem) Delete(hash checksum.Hash) {
+func (s *Mem) Delete(hash checksum.Hash) error {
 	s.dataMu.Lock()
 	defer s.dataMu.Unlock()
+	if _, ok := s.data[hash]; !ok {
+		return procs.MissingDataError{errors.New("no stored data")}
+	}
 	delete(s.data, hash)
+	return nil
+}
+
+func (s *Mem) Quarantine(hash checksum.Hash) error {
+	s.dataMu.Lock()
+	defer s.dataMu.Unlock()
+	data, ok := s.data[hash]
+	if !ok {
+		return procs.MissingDataError{errors.New("no stored data")}
+	}
+	delete(s.data, hash)
+	s.quarantine[hash] = data
+	return nil
+}
+
+func (s *Mem) Quarantined() (hashes []checksum.Hash) {
+	s.dataMu.RLock()
+	defer s.dataMu.RUnlock()
+	hashes = make([]checksum.Hash, 0, len(s.quarantine))
+	for h := range s.quarantine {
+		hashes = append(hashes, h)
+	}
+	return
 }
 
 func (s *Mem) Ls() ([]LsEntry, error) {
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
 	"github.com/Roman2K/scat/procs"
 	"github.com/Roman2K/scat/stores"
 	"github.com/Roman2K/scat/testutil"
@@ -31,3 +32,44 @@ func TestMemMissingData(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, data, string(b))
 }
+
+func TestMemCancel(t *testing.T) {
+	mem := stores.NewMem()
+	c := scat.NewChunk(0, nil)
+	mem.Set(c.Hash(), []byte("xxx"))
+	done := make(chan struct{})
+	close(done)
+	_, err := testutil.ReadChunks(mem.Unproc().Process(procs.WithDone(c, done)))
+	assert.Equal(t, procs.ErrCanceled, err)
+}
+
+func TestMemDelete(t *testing.T) {
+	var (
+		hash = testutil.Hash1.Hash
+	)
+
+	mem := stores.NewMem()
+	err := mem.Delete(hash)
+	assert.IsType(t, procs.MissingDataError{}, err)
+
+	mem.Set(hash, []byte("xxx"))
+	err = mem.Delete(hash)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(mem.Hashes()))
+}
+
+func TestMemQuarantine(t *testing.T) {
+	var (
+		hash = testutil.Hash1.Hash
+	)
+
+	mem := stores.NewMem()
+	err := mem.Quarantine(hash)
+	assert.IsType(t, procs.MissingDataError{}, err)
+
+	mem.Set(hash, []byte("xxx"))
+	err = mem.Quarantine(hash)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(mem.Hashes()))
+	assert.Equal(t, []checksum.Hash{hash}, mem.Quarantined())
+}
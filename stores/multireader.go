@@ -18,24 +18,39 @@ var (
 type mrd struct {
 	reg     *copies.Reg
 	copiers []Copier
+	health  *ReadHealth
 }
 
-func NewMultiReader(copiers []Copier) (proc procs.Proc, err error) {
+// Reads from the healthiest of copiers owning each chunk, falling back to the
+// others on error. A nil health is replaced with a new one.
+func NewMultiReader(copiers []Copier, health *ReadHealth) (
+	proc procs.Proc, err error,
+) {
 	ml := make(MultiLister, len(copiers))
 	for i, cp := range copiers {
 		ml[i] = cp
 	}
 	reg := copies.NewReg()
-	proc = mrd{
-		reg:     reg,
-		copiers: copiers,
-	}
+	proc = NewRegMultiReader(reg, copiers, health)
 	err = ml.AddEntriesTo([]LsEntryAdder{
 		CopiesEntryAdder{Reg: reg},
 	})
 	return
 }
 
+// Reads from copiers registered as owners in an already populated reg.
+func NewRegMultiReader(reg *copies.Reg, copiers []Copier, health *ReadHealth,
+) procs.Proc {
+	if health == nil {
+		health = NewReadHealth()
+	}
+	return mrd{
+		reg:     reg,
+		copiers: copiers,
+		health:  health,
+	}
+}
+
 var shuffle = ShuffleCopiers // var for tests
 
 func (mrd mrd) Process(c *scat.Chunk) <-chan procs.Res {
@@ -44,19 +59,26 @@ func (mrd mrd) Process(c *scat.Chunk) <-chan procs.Res {
 	for i, o := range owners {
 		copiers[i] = o.(Copier)
 	}
-	copiers = shuffle(copiers)
+	copiers = mrd.health.Order(copiers)
 	casc := make(procs.Cascade, len(copiers))
 	for i, cp := range copiers {
-		casc[i] = procs.OnEnd{cp, func(err error) {
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "multireader: copier error: %v\n", err)
-				mrd.reg.RemoveOwner(cp)
+		cp := cp
+		casc[i] = procs.OnEnd{mrd.health.Proc(cp.Id(), cp), func(err error) {
+			if err == nil || err == procs.ErrCanceled {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "multireader: copier error: %v\n", err)
+			if _, ok := err.(procs.MissingDataError); ok {
+				mrd.reg.List(c.Hash()).Remove(cp)
 			}
 		}}
 	}
 	if len(casc) == 0 {
 		return procs.SingleRes(c, procs.MissingDataError{errMultiReaderNoneAvail})
 	}
+	if delay, ok := mrd.health.HedgeDelay(); ok {
+		return procs.Hedged{casc, delay}.Process(c)
+	}
 	return casc.Process(c)
 }
 
@@ -1,6 +1,7 @@
 package stores
 
 import (
+	"errors"
 	"sort"
 	"testing"
 
@@ -32,7 +33,7 @@ func TestMultiReader(t *testing.T) {
 	c.SetHash(hash)
 
 	// none available
-	mrd, err := NewMultiReader(copiers)
+	mrd, err := NewMultiReader(copiers, nil)
 	assert.NoError(t, err)
 	chunks, err := testutil.ReadChunks(mrd.Process(c))
 	missErr, ok := err.(procs.MissingDataError)
@@ -51,15 +52,41 @@ func TestMultiReader(t *testing.T) {
 
 	// on mem2
 	mem2.Set(hash, []byte("data2"))
-	mrd, err = NewMultiReader(copiers)
+	mrd, err = NewMultiReader(copiers, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "data2", readData())
 
 	// on mem2 and mem1
 	mem1.Set(hash, []byte("data1"))
-	mrd, err = NewMultiReader(copiers)
+	mrd, err = NewMultiReader(copiers, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "data1", readData())
+
+	// failing: backed off from, not dropped
+	failing := Copier{"mem1", mem1, procs.ProcFunc(
+		func(c *scat.Chunk) <-chan procs.Res {
+			return procs.SingleRes(c, errors.New("read err"))
+		},
+	)}
+	health := NewReadHealth()
+	health.Explore = 0
+	mrd, err = NewMultiReader([]Copier{failing, copiers[1]}, health)
+	assert.NoError(t, err)
+	assert.Equal(t, "data2", readData())
+	assert.True(t, health.Stats("mem1").Open())
+	assert.Equal(t, uint64(1), health.Stats("mem2").Reads)
+	assert.Equal(t, "data2", readData())
+	assert.Equal(t, uint64(1), health.Stats("mem1").Reads)
+
+	// missing: no longer owner of that chunk
+	health = NewReadHealth()
+	mrd, err = NewMultiReader(copiers, health)
+	assert.NoError(t, err)
+	mem1.Delete(hash)
+	assert.Equal(t, "data2", readData())
+	assert.Equal(t, "data2", readData())
+	assert.Equal(t, uint64(1), health.Stats("mem1").Reads)
+	assert.False(t, health.Stats("mem1").Open())
 }
 
 func sortCopiersByIdString(s []Copier) (res []Copier) {
@@ -17,6 +17,11 @@ type useCb func(res Res, use, max uint64)
 type usage struct {
 	res      Res
 	use, max uint64
+	deleted  bool
+}
+
+func (u *usage) full() bool {
+	return u.deleted || u.use >= u.max
 }
 
 type Res interface {
@@ -35,7 +40,7 @@ func (man *Man) AddResQuota(res Res, max uint64) {
 	id := res.Id()
 	man.mu.Lock()
 	defer man.mu.Unlock()
-	if _, ok := man.m[id]; !ok {
+	if u, ok := man.m[id]; !ok || u.full() {
 		man.m[id] = &usage{res: res}
 	}
 	man.m[id].max = max
@@ -53,20 +58,43 @@ func (man *Man) addUse(res Res, use uint64) (u *usage, ok bool) {
 	man.mu.Lock()
 	defer man.mu.Unlock()
 	u, ok = man.m[id]
-	if !ok {
+	if !ok || u.full() {
+		ok = false
 		return
 	}
 	u.use += use
-	if u.use >= u.max {
-		delete(man.m, res.Id())
+	return
+}
+
+// Frees use, making res available again once no longer full, unless deleted.
+func (man *Man) RemoveUse(res Res, use uint64) {
+	u, ok := man.removeUse(res, use)
+	if ok && man.OnUse != nil {
+		man.OnUse(res, u.use, u.max)
 	}
+}
+
+func (man *Man) removeUse(res Res, use uint64) (u *usage, ok bool) {
+	man.mu.Lock()
+	defer man.mu.Unlock()
+	u, ok = man.m[res.Id()]
+	if !ok || u.deleted {
+		ok = false
+		return
+	}
+	if use > u.use {
+		use = u.use
+	}
+	u.use -= use
 	return
 }
 
 func (man *Man) Delete(res Res) {
 	man.mu.Lock()
 	defer man.mu.Unlock()
-	delete(man.m, res.Id())
+	if u, ok := man.m[res.Id()]; ok {
+		u.deleted = true
+	}
 }
 
 func (man *Man) Resources(use uint64) (ress []Res) {
@@ -74,7 +102,7 @@ func (man *Man) Resources(use uint64) (ress []Res) {
 	man.mu.RLock()
 	defer man.mu.RUnlock()
 	for _, u := range man.m {
-		if u.use+use > u.max {
+		if u.full() || u.use+use > u.max {
 			continue
 		}
 		ress = append(ress, u.res)
@@ -61,6 +61,17 @@ func TestMan(t *testing.T) {
 	// b) deleted, used = 1
 	man.AddUse(b, 1)
 	assert.Equal(t, []string{}, ids(man.Resources(0)))
+
+	// a) quota = 100, used = 89
+	man.RemoveUse(a, 11)
+	assert.Equal(t, []string{"a"}, ids(man.Resources(11)))
+	assert.Equal(t, []string{}, ids(man.Resources(12)))
+	man.RemoveUse(a, 1000)
+	assert.Equal(t, []string{"a"}, ids(man.Resources(100)))
+
+	// b) deleted for good
+	man.RemoveUse(b, 1)
+	assert.Equal(t, []string{"a"}, ids(man.Resources(0)))
 }
 
 func TestManOnUse(t *testing.T) {
@@ -5,8 +5,12 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"sync/atomic"
 
 	"github.com/Roman2K/scat"
 	"github.com/Roman2K/scat/checksum"
@@ -26,15 +30,64 @@ func init() {
 type Rclone struct {
 	Remote string
 	Tmp    *tmpdedup.Dir
+	Batch  int
+}
+
+var (
+	_ Store       = Rclone{}
+	_ Deleter     = Rclone{}
+	_ Quarantiner = Rclone{}
+	_ Batcher     = Rclone{}
+)
+
+func (rc Rclone) WithBatch(size int) (Store, error) {
+	rc.Batch = size
+	return rc, nil
 }
 
 func (rc Rclone) Proc() procs.Proc {
+	if rc.Batch > 0 {
+		return procs.NewBatch(rc.Batch, batchDelay, rc.processBatch)
+	}
 	return procs.NewPathCmdIn(rc.procCmd, rc.Tmp)
 }
 
 func (rc Rclone) procCmd(_ *scat.Chunk, path string) (*exec.Cmd, error) {
-	cmd := exec.Command("rclone", "copy", path, rc.Remote, "-q")
-	return cmd, nil
+	return rcloneCopy(path, rc.Remote), nil
+}
+
+var rcloneBatchSeq uint32
+
+// Staged in a directory copied as a whole.
+func (rc Rclone) processBatch(chunks []*scat.Chunk) (err error) {
+	name := fmt.Sprintf("batch%d", atomic.AddUint32(&rcloneBatchSeq, 1))
+	dir, wg, err := rc.Tmp.Get(name, func(path string) error {
+		return os.Mkdir(path, 0700)
+	})
+	if err != nil {
+		return
+	}
+	defer wg.Done()
+	files := make([]string, 0, len(chunks))
+	defer func() {
+		for _, f := range files {
+			os.Remove(f)
+		}
+	}()
+	for _, c := range chunks {
+		b, err := c.Data().Bytes()
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%x", c.Hash()))
+		files = append(files, path)
+		err = ioutil.WriteFile(path, b, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = rcloneCopy(dir, rc.Remote).Output()
+	return
 }
 
 func (rc Rclone) Unproc() procs.Proc {
@@ -60,6 +113,9 @@ func (rc Rclone) Unproc() procs.Proc {
 // bytes on stdout.
 //
 func rcloneDownloadErr(res procs.Res) error {
+	if res.Err == procs.ErrCanceled {
+		return res.Err
+	}
 	if res.Err != nil {
 		return procs.MissingDataError{res.Err}
 	}
@@ -97,6 +153,31 @@ func (rc Rclone) loadCmd(c *scat.Chunk) (*exec.Cmd, error) {
 	return cmd, nil
 }
 
+func (rc Rclone) Delete(hash checksum.Hash) (err error) {
+	remote := fmt.Sprintf("%s/%x", rc.Remote, hash)
+	_, err = rcloneDeletefile(remote).Output()
+	return rcloneMissingErr(err)
+}
+
+// Same naming as Dir.QuarantinePath: not listed by Ls.
+func (rc Rclone) Quarantine(hash checksum.Hash) (err error) {
+	var (
+		src = fmt.Sprintf("%s/%x", rc.Remote, hash)
+		dst = fmt.Sprintf("%s/%s/corrupt-%x", rc.Remote, quarantineDir, hash)
+	)
+	_, err = rcloneMoveto(src, dst).Output()
+	return rcloneMissingErr(err)
+}
+
+func rcloneMissingErr(err error) error {
+	if exit, ok := err.(*exec.ExitError); ok {
+		if rcloneNotFoundRe.Match(exit.Stderr) {
+			return procs.MissingDataError{exit}
+		}
+	}
+	return err
+}
+
 func (rc Rclone) Ls() (entries []LsEntry, err error) {
 	cmd := rcloneLs(rc.Remote)
 	out, err := cmd.Output()
@@ -125,10 +206,19 @@ func (rc Rclone) Ls() (entries []LsEntry, err error) {
 
 // vars for tests
 var (
+	rcloneCopy = func(src, dst string) *exec.Cmd {
+		return exec.Command("rclone", "copy", src, dst, "-q")
+	}
 	rcloneLs = func(remote string) *exec.Cmd {
 		return exec.Command("rclone", "ls", remote, "-q")
 	}
 	rcloneCat = func(remote string) *exec.Cmd {
 		return exec.Command("rclone", "cat", remote)
 	}
+	rcloneDeletefile = func(remote string) *exec.Cmd {
+		return exec.Command("rclone", "deletefile", remote, "-q")
+	}
+	rcloneMoveto = func(src, dst string) *exec.Cmd {
+		return exec.Command("rclone", "moveto", src, dst, "-q")
+	}
 )
@@ -2,13 +2,17 @@ package stores
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/Roman2K/scat"
 	"github.com/Roman2K/scat/procs"
 	"github.com/Roman2K/scat/testutil"
+	"github.com/Roman2K/scat/tmpdedup"
 	assert "github.com/stretchr/testify/require"
 )
 
@@ -102,3 +106,116 @@ func TestRcloneLs(t *testing.T) {
 	assert.Equal(t, int64(27), entries[1].Size)
 	assert.Equal(t, e1h, fmt.Sprintf("%x", entries[1].Hash))
 }
+
+func TestRcloneDelete(t *testing.T) {
+	origDeletefile := rcloneDeletefile
+	defer func() {
+		rcloneDeletefile = origDeletefile
+	}()
+
+	var (
+		hash = testutil.Hash1.Hash
+		hex  = testutil.Hash1.Hex
+	)
+
+	remote, exitCode, errOut := "", 0, ""
+	rcloneDeletefile = func(r string) *exec.Cmd {
+		remote = r
+		return exec.Command("bash", "-c", fmt.Sprintf(
+			`echo -n %q >&2; exit %d`, errOut, exitCode,
+		))
+	}
+	rc := Rclone{Remote: "drive:tmp"}
+
+	exitCode, errOut = 0, ""
+	err := rc.Delete(hash)
+	assert.NoError(t, err)
+	assert.Equal(t, "drive:tmp/"+hex, remote)
+
+	exitCode, errOut = 1, "2017/02/01 10:17:01 object not found"
+	err = rc.Delete(hash)
+	missErr, ok := err.(procs.MissingDataError)
+	assert.True(t, ok)
+	assert.IsType(t, &exec.ExitError{}, missErr.Err)
+
+	exitCode, errOut = 1, "some other err"
+	err = rc.Delete(hash)
+	assert.IsType(t, &exec.ExitError{}, err)
+}
+
+func TestRcloneQuarantine(t *testing.T) {
+	origMoveto := rcloneMoveto
+	defer func() {
+		rcloneMoveto = origMoveto
+	}()
+
+	var (
+		hash = testutil.Hash1.Hash
+		hex  = testutil.Hash1.Hex
+	)
+
+	src, dst, exitCode, errOut := "", "", 0, ""
+	rcloneMoveto = func(s, d string) *exec.Cmd {
+		src, dst = s, d
+		return exec.Command("bash", "-c", fmt.Sprintf(
+			`echo -n %q >&2; exit %d`, errOut, exitCode,
+		))
+	}
+	rc := Rclone{Remote: "drive:tmp"}
+
+	exitCode, errOut = 0, ""
+	err := rc.Quarantine(hash)
+	assert.NoError(t, err)
+	assert.Equal(t, "drive:tmp/"+hex, src)
+	assert.Equal(t, "drive:tmp/quarantine/corrupt-"+hex, dst)
+
+	exitCode, errOut = 1, "2017/02/01 10:17:01 object not found"
+	err = rc.Quarantine(hash)
+	assert.IsType(t, procs.MissingDataError{}, err)
+
+	exitCode, errOut = 1, "some other err"
+	err = rc.Quarantine(hash)
+	assert.IsType(t, &exec.ExitError{}, err)
+}
+
+func TestRcloneBatch(t *testing.T) {
+	origCopy := rcloneCopy
+	defer func() {
+		rcloneCopy = origCopy
+	}()
+
+	dst, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dst)
+	tmp, err := tmpdedup.TempDir("")
+	assert.NoError(t, err)
+	defer tmp.Finish()
+
+	var srcs []string
+	rcloneCopy = func(src, remote string) *exec.Cmd {
+		srcs = append(srcs, src)
+		return exec.Command("cp", "-R", src+"/.", remote)
+	}
+	rc := Rclone{Remote: dst, Tmp: tmp, Batch: 2}
+	proc := rc.Proc()
+	chs := make([]<-chan procs.Res, len(testutil.Hashes))
+	for i, h := range testutil.Hashes {
+		c := scat.NewChunk(i, scat.BytesData(h.Hex[:i+1]))
+		c.SetHash(h.Hash)
+		chs[i] = proc.Process(c)
+	}
+	for _, ch := range chs {
+		_, err := testutil.ReadChunks(ch)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, len(srcs))
+	for i, h := range testutil.Hashes {
+		b, err := ioutil.ReadFile(filepath.Join(dst, h.Hex))
+		assert.NoError(t, err)
+		assert.Equal(t, h.Hex[:i+1], string(b))
+	}
+	tmp.TmpMan().Wait()
+	assert.Equal(t, 0, tmp.TmpMan().Len())
+	_, err = os.Stat(srcs[0])
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,323 @@
+package stores
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/tmpdedup"
+)
+
+// Same as Rclone, but through the remote control API of a long-running
+// `rclone rcd`, keeping OAuth tokens and connections across operations.
+type RcloneRc struct {
+	Remote string
+	Tmp    *tmpdedup.Dir
+	Rcd    *RcloneRcd
+}
+
+var (
+	_ Store       = RcloneRc{}
+	_ Deleter     = RcloneRc{}
+	_ Quarantiner = RcloneRc{}
+)
+
+func (rc RcloneRc) Proc() procs.Proc {
+	return procs.NewPathIn(rc.process, rc.Tmp)
+}
+
+// The daemon reads the temporary file: it must run on the same host.
+func (rc RcloneRc) process(c *scat.Chunk, path string) error {
+	return rc.Rcd.Call("operations/copyfile", map[string]string{
+		"srcFs":     filepath.Dir(path),
+		"srcRemote": filepath.Base(path),
+		"dstFs":     rc.Remote,
+		"dstRemote": fmt.Sprintf("%x", c.Hash()),
+	}, nil)
+}
+
+func (rc RcloneRc) Unproc() procs.Proc {
+	return procs.Filter{
+		Proc: procs.ChunkFunc(rc.unprocess),
+		Filter: func(res procs.Res) procs.Res {
+			if err := rcloneDownloadErr(res); err != nil {
+				res.Err = err
+			}
+			return res
+		},
+	}
+}
+
+func (rc RcloneRc) unprocess(c *scat.Chunk) (new *scat.Chunk, err error) {
+	ctx, cancel := chunkContext(c)
+	defer cancel()
+	b, err := rc.Rcd.Cat(ctx, rc.Remote, fmt.Sprintf("%x", c.Hash()))
+	if err != nil && ctx.Err() != nil {
+		err = procs.ErrCanceled
+	}
+	new = c.WithData(scat.BytesData(b))
+	return
+}
+
+func (rc RcloneRc) Delete(hash checksum.Hash) error {
+	err := rc.Rcd.Call("operations/deletefile", map[string]string{
+		"fs":     rc.Remote,
+		"remote": fmt.Sprintf("%x", hash),
+	}, nil)
+	return rcloneRcMissingErr(err)
+}
+
+// Same naming as Dir.QuarantinePath: not listed by Ls.
+func (rc RcloneRc) Quarantine(hash checksum.Hash) error {
+	err := rc.Rcd.Call("operations/movefile", map[string]string{
+		"srcFs":     rc.Remote,
+		"srcRemote": fmt.Sprintf("%x", hash),
+		"dstFs":     rc.Remote,
+		"dstRemote": fmt.Sprintf("%s/corrupt-%x", quarantineDir, hash),
+	}, nil)
+	return rcloneRcMissingErr(err)
+}
+
+func rcloneRcMissingErr(err error) error {
+	if e, ok := err.(rcloneRcError); ok {
+		if e.Status == http.StatusNotFound || rcloneNotFoundRe.MatchString(e.Msg) {
+			return procs.MissingDataError{e}
+		}
+	}
+	return err
+}
+
+func (rc RcloneRc) Ls() (entries []LsEntry, err error) {
+	res := struct {
+		List []struct {
+			Name  string
+			Size  int64
+			IsDir bool
+		} `json:"list"`
+	}{}
+	err = rc.Rcd.Call("operations/list", map[string]interface{}{
+		"fs":     rc.Remote,
+		"remote": "",
+		"opt":    map[string]bool{"filesOnly": true},
+	}, &res)
+	if err != nil {
+		return
+	}
+	entries = make([]LsEntry, 0, len(res.List))
+	var (
+		buf   = make([]byte, checksum.Size)
+		entry LsEntry
+	)
+	for _, f := range res.List {
+		if f.IsDir {
+			continue
+		}
+		n, err := fmt.Sscanf(f.Name, "%x", &buf)
+		if err != nil || n != 1 {
+			continue
+		}
+		err = entry.Hash.LoadSlice(buf)
+		if err != nil {
+			continue
+		}
+		entry.Size = f.Size
+		entries = append(entries, entry)
+	}
+	return
+}
+
+// An `rclone rcd` listening at URL, credentials included, with --rc-serve for
+// downloads. Without URL, one is started on first use, on a random local port,
+// until Stop.
+type RcloneRcd struct {
+	URL     string
+	once    sync.Once
+	err     error
+	cmd     *exec.Cmd
+	exited  chan struct{}
+	waitErr error
+}
+
+func NewRcloneRcd(url string) *RcloneRcd {
+	return &RcloneRcd{URL: strings.TrimSuffix(url, "/")}
+}
+
+// Shared by rclonerc stores not given the URL of a running daemon.
+var SharedRcloneRcd = &RcloneRcd{}
+
+const rcloneRcdStartTimeout = 30 * time.Second
+
+var errRcloneRcdExited = errors.New("rclone rcd exited")
+
+// var for tests
+var rcloneRcdCmd = func(addr, user, pass string) *exec.Cmd {
+	return exec.Command("rclone", "rcd",
+		"--rc-addr", addr,
+		"--rc-user", user,
+		"--rc-pass", pass,
+		"--rc-serve",
+		"-q",
+	)
+}
+
+func (d *RcloneRcd) init() error {
+	d.once.Do(func() {
+		if d.URL == "" {
+			d.err = d.start()
+		}
+	})
+	return d.err
+}
+
+func (d *RcloneRcd) start() (err error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	pass := make([]byte, 16)
+	_, err = rand.Read(pass)
+	if err != nil {
+		return
+	}
+	u := &url.URL{
+		Scheme: "http",
+		Host:   addr,
+		User:   url.UserPassword("scat", hex.EncodeToString(pass)),
+	}
+	d.URL = u.String()
+	cmd := rcloneRcdCmd(addr, "scat", hex.EncodeToString(pass))
+	cmd.Stderr = os.Stderr
+	err = cmd.Start()
+	if err != nil {
+		return
+	}
+	d.cmd = cmd
+	d.exited = make(chan struct{})
+	go func() {
+		d.waitErr = cmd.Wait()
+		close(d.exited)
+	}()
+	deadline := time.Now().Add(rcloneRcdStartTimeout)
+	for {
+		err = d.call("rc/noop", struct{}{}, nil)
+		if err == nil {
+			return
+		}
+		select {
+		case <-d.exited:
+			return errRcloneRcdExited
+		case <-time.After(50 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("rclone rcd not ready: %v", err)
+		}
+	}
+}
+
+func (d *RcloneRcd) Stop() error {
+	d.once.Do(func() {})
+	if d.cmd == nil {
+		return nil
+	}
+	select {
+	case <-d.exited:
+		return d.waitErr
+	default:
+	}
+	err := d.cmd.Process.Signal(os.Interrupt)
+	if err != nil {
+		return err
+	}
+	<-d.exited
+	return nil
+}
+
+func (d *RcloneRcd) Call(method string, in, out interface{}) error {
+	if err := d.init(); err != nil {
+		return err
+	}
+	return d.call(method, in, out)
+}
+
+func (d *RcloneRcd) call(method string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(
+		d.URL+"/"+method, "application/json", bytes.NewReader(body),
+	)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		e := rcloneRcError{Status: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(&struct {
+			Error *string `json:"error"`
+		}{&e.Msg})
+		return e
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Downloads a file, served under /[fs]/remote with --rc-serve, until ctx is
+// canceled.
+func (d *RcloneRcd) Cat(ctx context.Context, fs, remote string) (
+	b []byte, err error,
+) {
+	err = d.init()
+	if err != nil {
+		return
+	}
+	u := d.URL + (&url.URL{Path: "/[" + fs + "]/" + remote}).EscapedPath()
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = rcloneRcError{resp.StatusCode, strings.TrimSpace(string(b))}
+		b = nil
+	}
+	return
+}
+
+type rcloneRcError struct {
+	Status int
+	Msg    string
+}
+
+func (e rcloneRcError) Error() string {
+	return fmt.Sprintf("rclone rc: %d: %s", e.Status, e.Msg)
+}
@@ -0,0 +1,235 @@
+package stores
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/testutil"
+	"github.com/Roman2K/scat/tmpdedup"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestRcloneRc(t *testing.T) {
+	fake := &fakeRc{files: make(map[string][]byte)}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	tmp, err := tmpdedup.TempDir("")
+	assert.NoError(t, err)
+	defer tmp.Finish()
+	rc := RcloneRc{"remote:dir", tmp, NewRcloneRcd(ts.URL + "/")}
+
+	var (
+		hash1 = testutil.Hash1.Hash
+		hash2 = testutil.Hashes[1].Hash
+		file1 = "remote:dir/" + testutil.Hash1.Hex
+	)
+	put := func(hash checksum.Hash, data string) {
+		c := scat.NewChunk(0, scat.BytesData(data))
+		c.SetHash(hash)
+		_, err := testutil.ReadChunks(rc.Proc().Process(c))
+		assert.NoError(t, err)
+	}
+	get := func(hash checksum.Hash) (string, error) {
+		c := scat.NewChunk(0, nil)
+		c.SetHash(hash)
+		chunks, err := testutil.ReadChunks(rc.Unproc().Process(c))
+		if err != nil {
+			return "", err
+		}
+		b, err := chunks[0].Data().Bytes()
+		return string(b), err
+	}
+	assertMissing := func(err error) {
+		_, ok := err.(procs.MissingDataError)
+		assert.True(t, ok, "not a missing data error: %v", err)
+	}
+
+	// write, read
+	put(hash1, "abc")
+	put(hash2, "de")
+	assert.Equal(t, "abc", string(fake.files[file1]))
+	tmp.TmpMan().Wait()
+	assert.Equal(t, 0, tmp.TmpMan().Len())
+	data, err := get(hash1)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", data)
+
+	// ls
+	fake.files["remote:dir/foo"] = []byte("x")
+	fake.files["remote:other/"+testutil.Hash1.Hex] = []byte("x")
+	ls, err := rc.Ls()
+	assert.NoError(t, err)
+	sort.Slice(ls, func(i, j int) bool {
+		return ls[i].Size > ls[j].Size
+	})
+	assert.Equal(t, []LsEntry{{hash1, 3}, {hash2, 2}}, ls)
+
+	// quarantine
+	err = rc.Quarantine(hash1)
+	assert.NoError(t, err)
+	_, ok := fake.files[file1]
+	assert.False(t, ok)
+	quar := "remote:dir/quarantine/corrupt-" + testutil.Hash1.Hex
+	assert.Equal(t, "abc", string(fake.files[quar]))
+
+	// missing
+	_, err = get(hash1)
+	assertMissing(err)
+	err = rc.Quarantine(hash1)
+	assertMissing(err)
+	err = rc.Delete(hash1)
+	assertMissing(err)
+
+	// empty download
+	fake.files[file1] = nil
+	_, err = get(hash1)
+	assertMissing(err)
+	assert.Equal(t, errRcloneZeroBytes, err.(procs.MissingDataError).Err)
+
+	// delete
+	err = rc.Delete(hash2)
+	assert.NoError(t, err)
+	ls, err = rc.Ls()
+	assert.NoError(t, err)
+	assert.Equal(t, []LsEntry{{hash1, 0}}, ls)
+
+	// other errors
+	fake.fail = true
+	err = rc.Delete(hash2)
+	assert.Error(t, err)
+	_, ok = err.(procs.MissingDataError)
+	assert.False(t, ok)
+}
+
+// Keyed by fs:path, regardless of fs/remote separation
+type fakeRc struct {
+	files map[string][]byte
+	fail  bool
+	mu    sync.Mutex
+}
+
+func (f *fakeRc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Method == "GET" {
+		f.serve(w, r)
+		return
+	}
+	in := map[string]interface{}{}
+	err := json.NewDecoder(r.Body).Decode(&in)
+	if err != nil {
+		f.error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if f.fail {
+		f.error(w, http.StatusInternalServerError, "failed")
+		return
+	}
+	path := func(fs, remote string) string {
+		return in[fs].(string) + "/" + in[remote].(string)
+	}
+	switch r.URL.Path {
+	case "/operations/copyfile":
+		src := filepath.Join(in["srcFs"].(string), in["srcRemote"].(string))
+		b, err := ioutil.ReadFile(src)
+		if err != nil {
+			f.error(w, http.StatusNotFound, err.Error())
+			return
+		}
+		f.files[path("dstFs", "dstRemote")] = b
+	case "/operations/movefile":
+		src := path("srcFs", "srcRemote")
+		b, ok := f.files[src]
+		if !ok {
+			f.error(w, http.StatusInternalServerError, "object not found")
+			return
+		}
+		delete(f.files, src)
+		f.files[path("dstFs", "dstRemote")] = b
+	case "/operations/deletefile":
+		p := path("fs", "remote")
+		if _, ok := f.files[p]; !ok {
+			f.error(w, http.StatusNotFound, "object not found")
+			return
+		}
+		delete(f.files, p)
+	case "/operations/list":
+		type item struct {
+			Name  string
+			Size  int64
+			IsDir bool
+		}
+		list := []item{}
+		dir := path("fs", "remote")
+		for p, b := range f.files {
+			if strings.HasPrefix(p, dir) &&
+				!strings.Contains(strings.TrimPrefix(p, dir), "/") {
+				list = append(list, item{Name: strings.TrimPrefix(p, dir),
+					Size: int64(len(b)),
+				})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"list": list})
+		return
+	default:
+		f.error(w, http.StatusNotFound, "couldn't find method")
+		return
+	}
+	w.Write([]byte("{}"))
+}
+
+func (f *fakeRc) serve(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, "/[")
+	p = strings.Replace(p, "]/", "/", 1)
+	b, ok := f.files[p]
+	if !ok {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	w.Write(b)
+}
+
+func (f *fakeRc) error(w http.ResponseWriter, status int, msg string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  msg,
+		"status": status,
+	})
+}
+
+func TestRcloneRcCancel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		},
+	))
+	defer ts.Close()
+	rc := RcloneRc{"remote:dir", nil, NewRcloneRcd(ts.URL + "/")}
+
+	done := make(chan struct{})
+	c := procs.WithDone(scat.NewChunk(0, nil), done)
+	c.SetHash(testutil.Hash1.Hash)
+	errc := make(chan error)
+	go func() {
+		_, err := testutil.ReadChunks(rc.Unproc().Process(c))
+		errc <- err
+	}()
+	close(done)
+	select {
+	case err := <-errc:
+		assert.Equal(t, procs.ErrCanceled, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("read not canceled")
+	}
+}
@@ -0,0 +1,226 @@
+package stores
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/procs"
+)
+
+// Read latency, throughput and errors of copiers, by which multireaders order
+// them. Copiers failing with other than missing data are backed off from, for
+// a time doubling with each consecutive failure: only read from once the
+// others have failed too, until one read succeeds.
+//
+// With Hedge set, reads taking longer than that percentile of the latest
+// successful ones are raced against a read from the next copier.
+type ReadHealth struct {
+	OnUpdate func(id interface{}, st ReadStats)
+	Explore  float64 // share of reads from healthy copiers in random order
+	Hedge    float64 // percentile, 0 for no hedging
+
+	m      map[interface{}]*ReadStats
+	recent []time.Duration
+	pos    int
+	mu     sync.Mutex
+}
+
+type ReadStats struct {
+	Latency   time.Duration // moving average of successful reads
+	Rate      uint64        // moving average, in bytes/s
+	Reads     uint64
+	Errors    uint64
+	Failures  int // consecutive, missing data excluded
+	OpenUntil time.Time
+}
+
+func (st ReadStats) Open() bool {
+	return timeNow().Before(st.OpenUntil)
+}
+
+const (
+	readHealthWeight = 0.2 // of the latest read in moving averages
+	readBackoffMin   = 2 * time.Second
+	readBackoffMax   = 2 * time.Minute
+	readHedgeSamples = 256
+	readHedgeMin     = 16 // samples before hedging
+)
+
+var timeNow = time.Now // var for tests
+
+func NewReadHealth() *ReadHealth {
+	return &ReadHealth{
+		Explore: 0.1,
+		m:       make(map[interface{}]*ReadStats),
+	}
+}
+
+func (h *ReadHealth) Stats(id interface{}) ReadStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return *h.stats(id)
+}
+
+func (h *ReadHealth) stats(id interface{}) *ReadStats {
+	st, ok := h.m[id]
+	if !ok {
+		st = &ReadStats{}
+		h.m[id] = st
+	}
+	return st
+}
+
+// Healthy copiers first, never read from then fastest first, then the ones
+// backed off from, soonest available first. Ties are shuffled.
+func (h *ReadHealth) Order(copiers []Copier) []Copier {
+	copiers = shuffle(copiers)
+	stats := make([]ReadStats, len(copiers))
+	h.mu.Lock()
+	for i, cp := range copiers {
+		stats[i] = *h.stats(cp.Id())
+	}
+	h.mu.Unlock()
+	explore := rand.Float64() < h.Explore
+	less := func(a, b ReadStats) bool {
+		if a.Open() || b.Open() {
+			return !a.Open() || b.Open() && a.OpenUntil.Before(b.OpenUntil)
+		}
+		if explore {
+			return false
+		}
+		if a.Latency == 0 || b.Latency == 0 {
+			return a.Latency == 0 && b.Latency != 0
+		}
+		return a.Latency < b.Latency
+	}
+	sort.Stable(copiersByStats{copiers, stats, less})
+	return copiers
+}
+
+type copiersByStats struct {
+	copiers []Copier
+	stats   []ReadStats
+	less    func(a, b ReadStats) bool
+}
+
+func (s copiersByStats) Len() int {
+	return len(s.copiers)
+}
+
+func (s copiersByStats) Less(i, j int) bool {
+	return s.less(s.stats[i], s.stats[j])
+}
+
+func (s copiersByStats) Swap(i, j int) {
+	s.copiers[i], s.copiers[j] = s.copiers[j], s.copiers[i]
+	s.stats[i], s.stats[j] = s.stats[j], s.stats[i]
+}
+
+func (h *ReadHealth) HedgeDelay() (d time.Duration, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.Hedge <= 0 || len(h.recent) < readHedgeMin {
+		return
+	}
+	sorted := make([]time.Duration, len(h.recent))
+	copy(sorted, h.recent)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	i := int(math.Ceil(h.Hedge/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i], true
+}
+
+// Records reads by proc, on behalf of id.
+func (h *ReadHealth) Proc(id interface{}, proc procs.Proc) procs.Proc {
+	return procs.ProcFunc(func(c *scat.Chunk) <-chan procs.Res {
+		start := timeNow()
+		ch := proc.Process(c)
+		out := make(chan procs.Res)
+		go func() {
+			defer close(out)
+			var (
+				err  error
+				size int64
+			)
+			for res := range ch {
+				if res.Err != nil && err == nil {
+					err = res.Err
+				}
+				if c := res.Chunk; c != nil {
+					if sz, ok := c.Data().(scat.Sizer); ok {
+						size += int64(sz.Size())
+					}
+				}
+				out <- res
+			}
+			if err != procs.ErrCanceled {
+				h.record(id, timeNow().Sub(start), size, err)
+			}
+		}()
+		return out
+	})
+}
+
+func (h *ReadHealth) record(id interface{}, dur time.Duration, size int64,
+	err error,
+) {
+	h.mu.Lock()
+	st := h.stats(id)
+	st.Reads++
+	if err != nil {
+		st.Errors++
+		if _, ok := err.(procs.MissingDataError); !ok {
+			st.Failures++
+			st.OpenUntil = timeNow().Add(readBackoff(st.Failures))
+		}
+	} else {
+		st.Failures = 0
+		st.OpenUntil = time.Time{}
+		if dur <= 0 {
+			dur = 1
+		}
+		if len(h.recent) < readHedgeSamples {
+			h.recent = append(h.recent, dur)
+		} else {
+			h.recent[h.pos] = dur
+			h.pos = (h.pos + 1) % readHedgeSamples
+		}
+		rate := uint64(float64(size) / dur.Seconds())
+		if st.Latency == 0 {
+			st.Latency, st.Rate = dur, rate
+		} else {
+			st.Latency = time.Duration(movingAvg(float64(st.Latency), float64(dur)))
+			st.Rate = uint64(movingAvg(float64(st.Rate), float64(rate)))
+		}
+	}
+	cp := *st
+	h.mu.Unlock()
+	if h.OnUpdate != nil {
+		h.OnUpdate(id, cp)
+	}
+}
+
+func movingAvg(avg, val float64) float64 {
+	return avg + readHealthWeight*(val-avg)
+}
+
+func readBackoff(failures int) (d time.Duration) {
+	d = readBackoffMin
+	for i := 1; i < failures && d < readBackoffMax; i++ {
+		d *= 2
+	}
+	if d > readBackoffMax {
+		d = readBackoffMax
+	}
+	return
+}
@@ -0,0 +1,104 @@
+package stores
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Roman2K/scat/procs"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestReadHealth(t *testing.T) {
+	origShuffle, origNow := shuffle, timeNow
+	defer func() {
+		shuffle, timeNow = origShuffle, origNow
+	}()
+	shuffle = sortCopiersByIdString
+	now := time.Unix(0, 0)
+	timeNow = func() time.Time { return now }
+
+	var (
+		a, b, c = Copier{IdVal: "a"}, Copier{IdVal: "b"}, Copier{IdVal: "c"}
+		copiers = []Copier{a, b, c}
+		someErr = errors.New("some err")
+	)
+	h := NewReadHealth()
+	h.Explore = 0
+	updates := []string{}
+	h.OnUpdate = func(id interface{}, st ReadStats) {
+		updates = append(updates, id.(string))
+	}
+	ids := func() (ids []string) {
+		for _, cp := range h.Order(copiers) {
+			ids = append(ids, cp.Id().(string))
+		}
+		return
+	}
+
+	// unmeasured first, then fastest
+	h.record("a", 3*time.Second, 300, nil)
+	h.record("c", time.Second, 200, nil)
+	assert.Equal(t, []string{"b", "c", "a"}, ids())
+	h.record("b", 2*time.Second, 0, nil)
+	assert.Equal(t, []string{"c", "b", "a"}, ids())
+	assert.Equal(t, []string{"a", "c", "b"}, updates)
+	st := h.Stats("a")
+	assert.Equal(t, 3*time.Second, st.Latency)
+	assert.Equal(t, uint64(100), st.Rate)
+	h.record("a", time.Second, 100, nil)
+	st = h.Stats("a")
+	assert.Equal(t, 2600*time.Millisecond, st.Latency)
+	assert.Equal(t, uint64(100), st.Rate)
+	assert.Equal(t, uint64(2), st.Reads)
+
+	// missing data: no back-off
+	h.record("c", time.Second, 0, procs.MissingDataError{someErr})
+	st = h.Stats("c")
+	assert.False(t, st.Open())
+	assert.Equal(t, uint64(1), st.Errors)
+	assert.Equal(t, []string{"c", "b", "a"}, ids())
+
+	// failures: backed off, for longer each time
+	h.record("c", time.Second, 0, someErr)
+	h.record("b", time.Second, 0, someErr)
+	h.record("b", time.Second, 0, someErr)
+	assert.Equal(t, []string{"a", "c", "b"}, ids())
+	st = h.Stats("b")
+	assert.True(t, st.Open())
+	assert.Equal(t, 2, st.Failures)
+	assert.Equal(t, now.Add(2*readBackoffMin), st.OpenUntil)
+
+	// half-open once the back-off is over, closed on success
+	now = now.Add(readBackoffMin)
+	assert.Equal(t, []string{"c", "a", "b"}, ids())
+	h.record("b", time.Second, 0, nil)
+	st = h.Stats("b")
+	assert.False(t, st.Open())
+	assert.Equal(t, 0, st.Failures)
+	assert.Equal(t, 1800*time.Millisecond, st.Latency)
+	assert.Equal(t, []string{"c", "b", "a"}, ids())
+
+	// back-off cap
+	assert.Equal(t, readBackoffMax, readBackoff(100))
+}
+
+func TestReadHealthHedgeDelay(t *testing.T) {
+	h := NewReadHealth()
+	_, ok := h.HedgeDelay()
+	assert.False(t, ok)
+	for i := 1; i <= readHedgeSamples+100; i++ {
+		h.record("a", time.Duration(i)*time.Millisecond, 0, nil)
+	}
+	_, ok = h.HedgeDelay()
+	assert.False(t, ok)
+
+	// latest samples only
+	h.Hedge = 50
+	d, ok := h.HedgeDelay()
+	assert.True(t, ok)
+	assert.Equal(t, 228*time.Millisecond, d)
+	h.Hedge = 100
+	d, _ = h.HedgeDelay()
+	assert.Equal(t, 356*time.Millisecond, d)
+}
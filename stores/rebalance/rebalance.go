@@ -0,0 +1,294 @@
+package rebalance
+
+import (
+	"sync"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/stores"
+	"github.com/Roman2K/scat/stores/copies"
+	"github.com/Roman2K/scat/stores/quota"
+	"github.com/Roman2K/scat/stripe"
+)
+
+type Rebalance struct {
+	Stripe stripe.Striper
+	Qman   *quota.Man
+	Others []stores.NamedStore
+	Parity *Parity
+	Unproc procs.Proc // undoes the procs after checksum, to verify shards
+	Proc   procs.Proc // redoes them on rebuilt shards
+	Drain  bool
+	DryRun bool
+}
+
+type Report struct {
+	Id          interface{}
+	Copied      int
+	CopiedSize  int64
+	Deleted     int
+	DeletedSize int64
+}
+
+type Result struct {
+	Reports []Report
+	Rebuilt []checksum.Hash
+	Missing []checksum.Hash
+	Failed  []Failure
+}
+
+// Chunk that couldn't be read, or written to store Id.
+type Failure struct {
+	Hash checksum.Hash
+	Id   interface{} // nil for reads
+	Err  error
+}
+
+type run struct {
+	Rebalance
+	reg     *copies.Reg
+	mrd     procs.Proc
+	sizes   *sizes
+	seq     stripe.Seq
+	stores  map[interface{}]stores.NamedStore
+	readers map[interface{}]stores.Copier
+	dests   map[interface{}]struct{}
+	reports map[interface{}]*Report
+	rebuilt []checksum.Hash
+	missing []checksum.Hash
+	failed  []Failure
+}
+
+func (rb Rebalance) Run(groups [][]*scat.Chunk) (res Result, err error) {
+	r := &run{
+		Rebalance: rb,
+		reg:       copies.NewReg(),
+		sizes:     &sizes{m: make(map[checksum.Hash]int64)},
+		stores:    make(map[interface{}]stores.NamedStore),
+		readers:   make(map[interface{}]stores.Copier),
+		dests:     make(map[interface{}]struct{}),
+		reports:   make(map[interface{}]*Report),
+	}
+	all := []stores.NamedStore{}
+	rrItems := []interface{}{}
+	for _, res := range rb.Qman.Resources(0) {
+		s := res.(stores.NamedStore)
+		all = append(all, s)
+		rrItems = append(rrItems, s.Id())
+		r.dests[s.Id()] = struct{}{}
+	}
+	for _, s := range rb.Others {
+		if _, ok := r.dests[s.Id()]; !ok {
+			all = append(all, s)
+		}
+	}
+	r.seq = &stripe.RR{Items: rrItems}
+	ml := make(stores.MultiLister, len(all))
+	copiers := make([]stores.Copier, len(all))
+	for i, s := range all {
+		cp := stores.Copier{IdVal: s.Id(), Lister: s, Proc: s.Unproc()}
+		ml[i] = cp
+		copiers[i] = cp
+		r.stores[s.Id()] = s
+		r.readers[s.Id()] = cp
+		r.reports[s.Id()] = &Report{Id: s.Id()}
+	}
+	r.mrd = stores.NewRegMultiReader(r.reg, copiers, nil)
+	defer func() {
+		if ferr := r.mrd.Finish(); err == nil {
+			err = ferr
+		}
+	}()
+	err = ml.AddEntriesTo([]stores.LsEntryAdder{
+		stores.QuotaEntryAdder{Qman: rb.Qman},
+		stores.CopiesEntryAdder{Reg: r.reg},
+		r.sizes,
+	})
+	if err != nil {
+		return
+	}
+	for _, group := range groups {
+		if gerr := r.group(group); gerr != nil {
+			for _, c := range group {
+				r.failed = append(r.failed, Failure{c.Hash(), nil, gerr})
+			}
+		}
+	}
+	res.Reports = make([]Report, len(all))
+	for i, s := range all {
+		res.Reports[i] = *r.reports[s.Id()]
+	}
+	res.Rebuilt = r.rebuilt
+	res.Missing = r.missing
+	res.Failed = r.failed
+	return
+}
+
+func (r *run) group(group []*scat.Chunk) error {
+	cur := make(stripe.S, len(group))
+	missing := map[checksum.Hash]bool{}
+	for _, c := range group {
+		owners := r.reg.List(c.Hash()).Owners()
+		if len(owners) == 0 {
+			missing[c.Hash()] = true
+			continue
+		}
+		locs := make(stripe.Locs, len(owners))
+		for _, o := range owners {
+			locs.Add(o.Id())
+		}
+		cur[c.Hash()] = locs
+	}
+	rebuilt, err := r.rebuild(group, missing)
+	if err != nil {
+		return err
+	}
+	for _, c := range group {
+		hash := c.Hash()
+		if !missing[hash] {
+			continue
+		}
+		if _, ok := rebuilt[hash]; ok {
+			cur[hash] = make(stripe.Locs)
+			r.rebuilt = append(r.rebuilt, hash)
+			continue
+		}
+		r.missing = append(r.missing, hash)
+	}
+	if len(cur) == 0 {
+		return nil
+	}
+	use := uint64(0)
+	for item := range cur {
+		use += uint64(r.sizes.get(item.(checksum.Hash)))
+	}
+	dests := make(stripe.Locs)
+	for _, res := range r.Qman.Resources(use) {
+		dests.Add(res.Id())
+	}
+	newStripe, err := r.Stripe.Stripe(cur, dests, r.seq)
+	if err != nil {
+		return err
+	}
+	failed := map[checksum.Hash]bool{}
+	for item, locs := range newStripe {
+		hash := item.(checksum.Hash)
+		if len(locs) == 0 {
+			continue
+		}
+		if !r.copy(hash, rebuilt[hash], locs) {
+			failed[hash] = true
+		}
+	}
+	if !r.Drain {
+		return nil
+	}
+	for item, got := range cur {
+		hash := item.(checksum.Hash)
+		if failed[hash] {
+			continue
+		}
+		for id := range got {
+			if _, ok := r.dests[id]; ok {
+				continue
+			}
+			err = r.delete(hash, id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Failures are recorded for the chunk, and the store written to no longer
+// used.
+func (r *run) copy(hash checksum.Hash, data []byte, locs stripe.Locs) (
+	ok bool,
+) {
+	list := r.reg.List(hash)
+	size := r.sizes.get(hash)
+	c := scat.NewChunk(0, scat.BytesData(data))
+	c.SetHash(hash)
+	if data == nil && !r.DryRun {
+		read, err := r.read(c)
+		if err != nil {
+			r.failed = append(r.failed, Failure{hash, nil, err})
+			return
+		}
+		c = read
+	}
+	ok = true
+	for id := range locs {
+		dest := r.stores[id]
+		if !r.DryRun {
+			err := procChunk(dest.Proc(), c)
+			if err != nil {
+				r.Qman.Delete(dest)
+				r.failed = append(r.failed, Failure{hash, id, err})
+				ok = false
+				continue
+			}
+		}
+		list.Add(r.readers[id])
+		r.Qman.AddUse(dest, uint64(size))
+		rep := r.reports[id]
+		rep.Copied++
+		rep.CopiedSize += size
+	}
+	return
+}
+
+func (r *run) read(c *scat.Chunk) (*scat.Chunk, error) {
+	return procs.ProcessResult(r.mrd, c)
+}
+
+func (r *run) delete(hash checksum.Hash, id interface{}) (err error) {
+	s := r.stores[id]
+	if !r.DryRun {
+		del, ok := s.Store.(stores.Deleter)
+		if !ok {
+			return stores.ErrNoDelete
+		}
+		err = del.Delete(hash)
+		if err != nil {
+			return
+		}
+	}
+	r.reg.List(hash).Remove(s)
+	rep := r.reports[id]
+	rep.Deleted++
+	rep.DeletedSize += r.sizes.get(hash)
+	return
+}
+
+// Processes c with a proc of its own, finished once done.
+func procChunk(proc procs.Proc, c *scat.Chunk) (err error) {
+	_, err = procs.ProcessResult(proc, c)
+	if ferr := proc.Finish(); err == nil {
+		err = ferr
+	}
+	return
+}
+
+type sizes struct {
+	m  map[checksum.Hash]int64
+	mu sync.Mutex
+}
+
+func (s *sizes) AddLsEntry(_ stores.Lister, e stores.LsEntry) {
+	s.set(e.Hash, e.Size)
+}
+
+func (s *sizes) set(hash checksum.Hash, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[hash] = size
+}
+
+func (s *sizes) get(hash checksum.Hash) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m[hash]
+}
@@ -0,0 +1,334 @@
+package rebalance_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/stores"
+	"github.com/Roman2K/scat/stores/quota"
+	"github.com/Roman2K/scat/stores/rebalance"
+	"github.com/Roman2K/scat/stripe"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestRebalance(t *testing.T) {
+	var (
+		hash1 = checksum.SumBytes([]byte("a"))
+		hash2 = checksum.SumBytes([]byte("b"))
+	)
+
+	old := stores.NewMem()
+	old.Set(hash1, []byte("aaa"))
+	old.Set(hash2, []byte("bbb"))
+	mem1 := stores.NewMem()
+	mem2 := stores.NewMem()
+
+	newRb := func() rebalance.Rebalance {
+		qman := quota.NewMan()
+		qman.AddRes(stores.NamedStore{"mem1", mem1})
+		qman.AddRes(stores.NamedStore{"mem2", mem2})
+		return rebalance.Rebalance{
+			Stripe: stripe.Config{Min: 1, Excl: 2},
+			Qman:   qman,
+			Others: []stores.NamedStore{{"old", old}},
+		}
+	}
+	groups := [][]*scat.Chunk{newGroup(hash1, hash2)}
+
+	// dry run
+	rb := newRb()
+	rb.Drain = true
+	rb.DryRun = true
+	res, err := rb.Run(groups)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(res.Missing))
+	reports := reportsById(res.Reports)
+	assert.Equal(t, 1, reports["mem1"].Copied)
+	assert.Equal(t, 1, reports["mem2"].Copied)
+	assert.Equal(t, 2, reports["old"].Deleted)
+	assert.Equal(t, int64(6), reports["old"].DeletedSize)
+	assert.Equal(t, 2, len(old.Hashes()))
+	assert.Equal(t, 0, len(mem1.Hashes()))
+
+	// no drain
+	rb = newRb()
+	res, err = rb.Run(groups)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(old.Hashes()))
+	assert.Equal(t, 1, len(mem1.Hashes()))
+	assert.Equal(t, 1, len(mem2.Hashes()))
+	assert.NotEqual(t, mem1.Hashes(), mem2.Hashes())
+	for _, h := range append(mem1.Hashes(), mem2.Hashes()...) {
+		assert.Equal(t, old.Get(h), append(mem1.Get(h), mem2.Get(h)...))
+	}
+
+	// drain, nothing left to copy
+	rb = newRb()
+	rb.Drain = true
+	res, err = rb.Run(groups)
+	assert.NoError(t, err)
+	reports = reportsById(res.Reports)
+	assert.Equal(t, 0, reports["mem1"].Copied)
+	assert.Equal(t, 0, reports["mem2"].Copied)
+	assert.Equal(t, 2, reports["old"].Deleted)
+	assert.Equal(t, 0, len(old.Hashes()))
+	assert.Equal(t, 1, len(mem1.Hashes()))
+	assert.Equal(t, 1, len(mem2.Hashes()))
+
+	// missing
+	hash3 := checksum.SumBytes([]byte("c"))
+	res, err = newRb().Run([][]*scat.Chunk{newGroup(hash3)})
+	assert.NoError(t, err)
+	assert.Equal(t, []checksum.Hash{hash3}, res.Missing)
+}
+
+func TestRebalanceQuota(t *testing.T) {
+	var (
+		hash1 = checksum.SumBytes([]byte("a"))
+		hash2 = checksum.SumBytes([]byte("b"))
+	)
+
+	old := stores.NewMem()
+	old.Set(hash1, []byte("aaa"))
+	old.Set(hash2, []byte("bbb"))
+	mem1 := stores.NewMem()
+	mem2 := stores.NewMem()
+
+	qman := quota.NewMan()
+	qman.AddResQuota(stores.NamedStore{"mem1", mem1}, 4)
+	qman.AddRes(stores.NamedStore{"mem2", mem2})
+	rb := rebalance.Rebalance{
+		Stripe: stripe.Config{Min: 1},
+		Qman:   qman,
+		Others: []stores.NamedStore{{"old", old}},
+		Drain:  true,
+	}
+	_, err := rb.Run([][]*scat.Chunk{newGroup(hash1, hash2)})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(old.Hashes()))
+	assert.Equal(t, 0, len(mem1.Hashes()))
+	assert.Equal(t, 2, len(mem2.Hashes()))
+}
+
+func TestRebalanceCopyErr(t *testing.T) {
+	var (
+		hash1   = checksum.SumBytes([]byte("a"))
+		hash2   = checksum.SumBytes([]byte("b"))
+		someErr = errors.New("some err")
+	)
+
+	old := stores.NewMem()
+	old.Set(hash1, []byte("aaa"))
+	old.Set(hash2, []byte("bbb"))
+	mem := stores.NewMem()
+	failing := errStore{Mem: stores.NewMem(), err: someErr}
+	nfinish := 0
+	oldFinishes := finishCountStore{Mem: old, n: &nfinish}
+
+	qman := quota.NewMan()
+	qman.AddRes(stores.NamedStore{"failing", failing})
+	qman.AddRes(stores.NamedStore{"mem", mem})
+	rb := rebalance.Rebalance{
+		Stripe: stripe.Config{Min: 1},
+		Qman:   qman,
+		Others: []stores.NamedStore{{"old", oldFinishes}},
+		Drain:  true,
+	}
+	res, err := rb.Run([][]*scat.Chunk{newGroup(hash1), newGroup(hash2)})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, nfinish)
+
+	// failed copy kept in drained store, others carried on with
+	assert.Equal(t, 1, len(res.Failed))
+	failed := res.Failed[0]
+	assert.Equal(t, "failing", failed.Id)
+	assert.Equal(t, someErr, failed.Err)
+	assert.Equal(t, []checksum.Hash{failed.Hash}, old.Hashes())
+	assert.Equal(t, 1, len(mem.Hashes()))
+	assert.NotEqual(t, failed.Hash, mem.Hashes()[0])
+}
+
+type errStore struct {
+	*stores.Mem
+	err error
+}
+
+func (s errStore) Proc() procs.Proc {
+	return procs.InplaceFunc(func(*scat.Chunk) error {
+		return s.err
+	})
+}
+
+type finishCountStore struct {
+	*stores.Mem
+	n *int
+}
+
+func (s finishCountStore) Unproc() procs.Proc {
+	return finishCountProc{s.Mem.Unproc(), s.n}
+}
+
+type finishCountProc struct {
+	procs.Proc
+	n *int
+}
+
+func (p finishCountProc) Finish() error {
+	*p.n++
+	return p.Proc.Finish()
+}
+
+func reportsById(reports []rebalance.Report) map[interface{}]rebalance.Report {
+	m := make(map[interface{}]rebalance.Report, len(reports))
+	for _, rep := range reports {
+		m[rep.Id] = rep
+	}
+	return m
+}
+
+func TestRebalanceRebuild(t *testing.T) {
+	const (
+		ndata   = 2
+		nparity = 1
+	)
+
+	parity, err := procs.NewParity(ndata, nparity)
+	assert.NoError(t, err)
+	in := scat.NewChunk(0, scat.BytesData("abcdefgh"))
+	shards := []*scat.Chunk{}
+	for res := range parity.Proc().Process(in) {
+		assert.NoError(t, res.Err)
+		c := res.Chunk
+		b, err := c.Data().Bytes()
+		assert.NoError(t, err)
+		c.SetHash(checksum.SumBytes(b))
+		shards = append(shards, c)
+	}
+	assert.Equal(t, ndata+nparity, len(shards))
+
+	mem1 := stores.NewMem()
+	mem2 := stores.NewMem()
+	for _, c := range shards {
+		b, err := c.Data().Bytes()
+		assert.NoError(t, err)
+		mem1.Set(c.Hash(), b)
+	}
+	lost := shards[1]
+	mem1.Delete(lost.Hash())
+
+	group := make([]*scat.Chunk, len(shards))
+	for i, c := range shards {
+		group[i] = scat.NewChunk(i, nil)
+		group[i].SetHash(c.Hash())
+		group[i].SetTargetSize(c.TargetSize())
+	}
+	newRb := func() rebalance.Rebalance {
+		qman := quota.NewMan()
+		qman.AddRes(stores.NamedStore{"mem1", mem1})
+		qman.AddRes(stores.NamedStore{"mem2", mem2})
+		return rebalance.Rebalance{
+			Stripe: stripe.Config{Min: 1},
+			Qman:   qman,
+		}
+	}
+
+	// without parity
+	res, err := newRb().Run([][]*scat.Chunk{group})
+	assert.NoError(t, err)
+	assert.Equal(t, []checksum.Hash{lost.Hash()}, res.Missing)
+	assert.Equal(t, 0, len(res.Rebuilt))
+
+	// dry run
+	rb := newRb()
+	rb.Parity = &rebalance.Parity{Data: ndata, Parity: nparity}
+	rb.DryRun = true
+	res, err = rb.Run([][]*scat.Chunk{group})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(res.Missing))
+	assert.Equal(t, []checksum.Hash{lost.Hash()}, res.Rebuilt)
+	assert.Nil(t, append(mem1.Get(lost.Hash()), mem2.Get(lost.Hash())...))
+
+	// rebuild
+	rb.DryRun = false
+	res, err = rb.Run([][]*scat.Chunk{group})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(res.Missing))
+	assert.Equal(t, []checksum.Hash{lost.Hash()}, res.Rebuilt)
+	expected, err := lost.Data().Bytes()
+	assert.NoError(t, err)
+	got := append(mem1.Get(lost.Hash()), mem2.Get(lost.Hash())...)
+	assert.Equal(t, expected, got)
+
+	// too many missing
+	mem1.Delete(lost.Hash())
+	mem2.Delete(lost.Hash())
+	mem1.Delete(shards[0].Hash())
+	res, err = rb.Run([][]*scat.Chunk{group})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(res.Missing))
+	assert.Equal(t, 0, len(res.Rebuilt))
+}
+
+func TestRebalanceRebuildUnproc(t *testing.T) {
+	parity, err := procs.NewParity(2, 1)
+	assert.NoError(t, err)
+	zstd := procs.Zstd{}
+	in := scat.NewChunk(0, scat.BytesData("abcdefgh"))
+	mem := stores.NewMem()
+	shards := [][]byte{}
+	group := []*scat.Chunk{}
+	for res := range parity.Proc().Process(in) {
+		assert.NoError(t, res.Err)
+		b, err := res.Chunk.Data().Bytes()
+		assert.NoError(t, err)
+		shards = append(shards, b)
+		c := scat.NewChunk(len(group), nil)
+		c.SetHash(checksum.SumBytes(b))
+		c.SetTargetSize(res.Chunk.TargetSize())
+		group = append(group, c)
+		stored, err := procs.ProcessResult(zstd.Proc(), res.Chunk)
+		assert.NoError(t, err)
+		b, err = stored.Data().Bytes()
+		assert.NoError(t, err)
+		mem.Set(c.Hash(), b)
+	}
+	lost := group[1].Hash()
+	mem.Delete(lost)
+
+	// group of the wrong size failed, others carried on with
+	other := newGroup(checksum.SumBytes([]byte("x")))
+	qman := quota.NewMan()
+	qman.AddRes(stores.NamedStore{"mem", mem})
+	rb := rebalance.Rebalance{
+		Stripe: stripe.Config{Min: 1},
+		Qman:   qman,
+		Parity: &rebalance.Parity{Data: 2, Parity: 1},
+		Unproc: zstd.Unproc(),
+		Proc:   zstd.Proc(),
+	}
+	res, err := rb.Run([][]*scat.Chunk{other, group})
+	assert.NoError(t, err)
+	assert.Equal(t, []checksum.Hash{lost}, res.Rebuilt)
+	assert.Equal(t, 1, len(res.Failed))
+	assert.Equal(t, other[0].Hash(), res.Failed[0].Hash)
+
+	// stored processed like the others
+	c := scat.NewChunk(0, scat.BytesData(mem.Get(lost)))
+	c, err = procs.ProcessResult(zstd.Unproc(), c)
+	assert.NoError(t, err)
+	b, err := c.Data().Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, shards[1], b)
+}
+
+func newGroup(hashes ...checksum.Hash) (group []*scat.Chunk) {
+	group = make([]*scat.Chunk, len(hashes))
+	for i, h := range hashes {
+		group[i] = scat.NewChunk(i, nil)
+		group[i].SetHash(h)
+	}
+	return
+}
@@ -0,0 +1,155 @@
+package rebalance
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+)
+
+var errRebuildMissing = errors.New("missing from all stores")
+
+type Parity struct {
+	Data, Parity int
+}
+
+// Recomputes shards missing from all stores out of the rest of their parity
+// group. Requires stored data to be the shards as output by procs.NewParity,
+// once undone by Unproc, which gets verified against their hash. Rebuilt
+// shards are processed by Proc to be stored like the others.
+func (r *run) rebuild(group []*scat.Chunk, missing map[checksum.Hash]bool) (
+	rebuilt map[checksum.Hash][]byte, err error,
+) {
+	rebuilt = make(map[checksum.Hash][]byte, len(missing))
+	if r.Parity == nil || len(missing) == 0 {
+		return
+	}
+	nshards := r.Parity.Data + r.Parity.Parity
+	if len(group) != nshards {
+		err = fmt.Errorf("group size %d doesn't match parity shards %d",
+			len(group), nshards,
+		)
+		return
+	}
+	if len(missing) > r.Parity.Parity {
+		return
+	}
+	shardSize := int64(0)
+	for _, c := range group {
+		if !missing[c.Hash()] {
+			shardSize = r.sizes.get(c.Hash())
+			break
+		}
+	}
+	for hash := range missing {
+		r.sizes.set(hash, shardSize)
+	}
+	if r.DryRun {
+		for hash := range missing {
+			rebuilt[hash] = []byte{}
+		}
+		return
+	}
+	shards, err := r.shards(group, missing)
+	if err != nil {
+		return
+	}
+	for i, c := range group {
+		hash := c.Hash()
+		if !missing[hash] {
+			continue
+		}
+		if checksum.SumBytes(shards[i]) != hash {
+			err = fmt.Errorf("rebuilt shard %x: %v", hash,
+				procs.ErrIntegrityCheckFailed,
+			)
+			return
+		}
+		rebuilt[hash], err = r.redo(c, shards[i])
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (r *run) redo(c *scat.Chunk, shard []byte) (b []byte, err error) {
+	if r.Proc == nil {
+		b = shard
+		return
+	}
+	c = c.WithData(scat.BytesData(shard))
+	c, err = procs.ProcessResult(r.Proc, c)
+	if err != nil {
+		return
+	}
+	return c.Data().Bytes()
+}
+
+func (r *run) shards(group []*scat.Chunk, missing map[checksum.Hash]bool) (
+	shards [][]byte, err error,
+) {
+	parity, err := procs.NewParity(r.Parity.Data, r.Parity.Parity)
+	if err != nil {
+		return
+	}
+	grouper := procs.NewGroup(len(group))
+	var joined *scat.Chunk
+	for i, c := range group {
+		shard := scat.NewChunk(i, nil)
+		shard.SetHash(c.Hash())
+		shard.SetTargetSize(c.TargetSize())
+		var ch <-chan procs.Res
+		if missing[c.Hash()] {
+			err := procs.MissingDataError{errRebuildMissing}
+			ch = grouper.ProcessErr(shard, err)
+		} else if read, err := r.readVerified(shard); err != nil {
+			ch = grouper.ProcessErr(shard, err)
+		} else {
+			ch = grouper.Process(read)
+		}
+		for res := range ch {
+			if res.Err != nil {
+				return nil, res.Err
+			}
+			joined = res.Chunk
+		}
+	}
+	unproc := parity.Unproc()
+	defer unproc.Finish()
+	joined, err = procs.ProcessResult(unproc, joined)
+	if err != nil {
+		return
+	}
+	for res := range parity.Proc().Process(joined) {
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		b, err := res.Chunk.Data().Bytes()
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, b)
+	}
+	if len(shards) != len(group) {
+		err = errors.New("invalid number of rebuilt shards")
+	}
+	return
+}
+
+func (r *run) readVerified(c *scat.Chunk) (read *scat.Chunk, err error) {
+	read, err = r.read(c)
+	if err != nil {
+		return
+	}
+	if r.Unproc != nil {
+		read, err = procs.ProcessResult(r.Unproc, read)
+		if err != nil {
+			return
+		}
+	}
+	_, err = procs.ProcessResult(procs.ChecksumUnproc, read)
+	return
+}
@@ -0,0 +1,227 @@
+package stores
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+)
+
+// Client of Server, reached over HTTP at a URL, or else over the
+// stdin/stdout of a command such as "ssh host scat serve dir" started for
+// each connection.
+//
+// Uploads carry a digest the server verifies before atomically moving them
+// in place, and so do downloads for the client to verify.
+type Remote struct {
+	Http
+}
+
+var (
+	_ Store       = Remote{}
+	_ Deleter     = Remote{}
+	_ Quarantiner = Remote{}
+)
+
+// Max concurrent connections, hence commands
+const remoteConns = 8
+
+func NewRemote(target string) Remote {
+	if strings.Contains(target, "://") {
+		return Remote{NewHttp(target, nil, false)}
+	}
+	dial := func(context.Context, string, string) (net.Conn, error) {
+		return dialCmd(target)
+	}
+	return Remote{Http{
+		URL: "http://remote",
+		Client: &http.Client{Transport: &http.Transport{
+			DialContext:         dial,
+			MaxConnsPerHost:     remoteConns,
+			MaxIdleConnsPerHost: remoteConns,
+		}},
+	}}
+}
+
+func (r Remote) Proc() procs.Proc {
+	return procs.InplaceFunc(r.process)
+}
+
+func (r Remote) process(c *scat.Chunk) (err error) {
+	b, err := c.Data().Bytes()
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(b)
+	hdr := map[string]string{"Digest": digestHeader(sum[:])}
+	_, err = r.do("PUT", r.path(c.Hash()), hdr, b)
+	return
+}
+
+func (r Remote) Unproc() procs.Proc {
+	return procs.ChunkFunc(r.unprocess)
+}
+
+func (r Remote) unprocess(c *scat.Chunk) (new *scat.Chunk, err error) {
+	b, hdr, err := r.get(c)
+	if err != nil {
+		return
+	}
+	want, err := parseDigest(hdr.Get("Digest"))
+	if err != nil {
+		return
+	}
+	if sum := sha256.Sum256(b); want != nil && !bytes.Equal(sum[:], want) {
+		err = errDigestMismatch
+		return
+	}
+	new = c.WithData(scat.BytesData(b))
+	return
+}
+
+func (r Remote) Quarantine(hash checksum.Hash) (err error) {
+	dst, err := url.Parse(r.url(r.quarantinePath(hash)))
+	if err != nil {
+		return
+	}
+	dst.User = nil
+	hdr := map[string]string{"Destination": dst.String()}
+	_, err = r.do("MOVE", r.path(hash), hdr, nil)
+	return
+}
+
+func dialCmd(cmdStr string) (conn net.Conn, err error) {
+	inR, inW, err := os.Pipe()
+	if err != nil {
+		return
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		inR.Close()
+		inW.Close()
+		return
+	}
+	cmd := exec.Command("sh", "-c", cmdStr)
+	cmd.Stdin = inR
+	cmd.Stdout = outW
+	cmd.Stderr = os.Stderr
+	err = cmd.Start()
+	inR.Close()
+	outW.Close()
+	if err != nil {
+		inW.Close()
+		outR.Close()
+		return
+	}
+	conn = &pipeConn{r: outR, w: inW, close: cmd.Wait}
+	return
+}
+
+// Serves a single connection, until closed by the client.
+func ServeConn(h http.Handler, conn net.Conn) error {
+	done := make(chan struct{})
+	srv := &http.Server{
+		Handler: h,
+		ConnState: func(_ net.Conn, st http.ConnState) {
+			if st == http.StateClosed || st == http.StateHijacked {
+				close(done)
+			}
+		},
+	}
+	err := srv.Serve(&connListener{conn: conn, done: done})
+	if err == errConnListenerDone {
+		err = nil
+	}
+	return err
+}
+
+var errConnListenerDone = errors.New("connection done")
+
+type connListener struct {
+	conn net.Conn
+	done <-chan struct{}
+}
+
+func (l *connListener) Accept() (conn net.Conn, err error) {
+	if l.conn != nil {
+		conn, l.conn = l.conn, nil
+		return
+	}
+	<-l.done
+	err = errConnListenerDone
+	return
+}
+
+func (l *connListener) Close() error {
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+// Connection over a pair of pipes, like stdin/stdout
+type pipeConn struct {
+	r, w  *os.File
+	close func() error
+}
+
+func NewStdioConn() net.Conn {
+	return &pipeConn{r: os.Stdin, w: os.Stdout}
+}
+
+func (c *pipeConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *pipeConn) Write(b []byte) (int, error) {
+	return c.w.Write(b)
+}
+
+func (c *pipeConn) Close() (err error) {
+	err = c.w.Close()
+	if rerr := c.r.Close(); err == nil {
+		err = rerr
+	}
+	if c.close != nil {
+		if cerr := c.close(); err == nil {
+			err = cerr
+		}
+	}
+	return
+}
+
+func (c *pipeConn) LocalAddr() net.Addr  { return pipeAddr{} }
+func (c *pipeConn) RemoteAddr() net.Addr { return pipeAddr{} }
+
+func (c *pipeConn) SetDeadline(t time.Time) error {
+	err := c.r.SetDeadline(t)
+	if werr := c.w.SetDeadline(t); err == nil {
+		err = werr
+	}
+	return err
+}
+
+func (c *pipeConn) SetReadDeadline(t time.Time) error {
+	return c.r.SetReadDeadline(t)
+}
+
+func (c *pipeConn) SetWriteDeadline(t time.Time) error {
+	return c.w.SetWriteDeadline(t)
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
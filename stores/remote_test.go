@@ -0,0 +1,117 @@
+package stores
+
+import (
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/testutil"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestRemoteHttp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cp := Cp{dir, StrPart{2}}
+	ts := httptest.NewServer(Server{cp, "user", "pass"})
+	defer ts.Close()
+	t.Setenv("SCAT_HTTP_USER", "user")
+	t.Setenv("SCAT_HTTP_PASSWORD", "pass")
+
+	r := NewRemote(ts.URL + "/")
+	testHttp(t, r, readCp(t, cp))
+
+	// digest mismatch
+	hdr := map[string]string{"Digest": "sha-256=" + strings.Repeat("A", 43) + "="}
+	_, err = r.do("PUT", testutil.Hash1.Hex, hdr, []byte("abc"))
+	assert.Equal(t, http.StatusBadRequest, httpStatus(err))
+	files, err := ioutil.ReadDir(filepath.Join(dir, testutil.Hash1.Hex[:2]))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(files))
+
+	// unauthorized
+	r.Pass = "bad"
+	_, err = r.Ls()
+	assert.Equal(t, http.StatusUnauthorized, httpStatus(err))
+	r.Pass = "passpass"
+	_, err = r.Ls()
+	assert.Equal(t, http.StatusUnauthorized, httpStatus(err))
+
+	// over TLS
+	tls := httptest.NewTLSServer(Server{cp, "user", "pass"})
+	defer tls.Close()
+	r = NewRemote(tls.URL + "/")
+	r.Client = tls.Client()
+	_, err = r.Ls()
+	assert.NoError(t, err)
+}
+
+func TestRemoteConn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cp := Cp{dir, nil}
+	dial := func(context.Context, string, string) (net.Conn, error) {
+		c1, c2 := net.Pipe()
+		go ServeConn(Server{Cp: cp}, c2)
+		return c1, nil
+	}
+	r := Remote{Http{
+		URL:    "http://remote",
+		Client: &http.Client{Transport: &http.Transport{DialContext: dial}},
+	}}
+	testHttp(t, r, readCp(t, cp))
+}
+
+func TestRemoteBadDigest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Digest", "sha-256="+strings.Repeat("A", 43)+"=")
+			w.Write([]byte("abc"))
+		},
+	))
+	defer ts.Close()
+	r := NewRemote(ts.URL)
+	c := scat.NewChunk(0, nil)
+	c.SetHash(testutil.Hash1.Hash)
+	_, err := testutil.ReadChunks(r.Unproc().Process(c))
+	assert.Equal(t, errDigestMismatch, err)
+	_, ok := err.(procs.MissingDataError)
+	assert.False(t, ok)
+}
+
+func TestDialCmd(t *testing.T) {
+	conn, err := dialCmd("cat")
+	assert.NoError(t, err)
+	_, err = conn.Write([]byte("abc"))
+	assert.NoError(t, err)
+	buf := make([]byte, 3)
+	_, err = conn.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", string(buf))
+	assert.NoError(t, conn.Close())
+}
+
+func readCp(t *testing.T, cp Cp) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		path := filepath.Join(cp.Path, name)
+		if b, err := hex.DecodeString(strings.TrimPrefix(name, "/")); err == nil {
+			var hash checksum.Hash
+			assert.NoError(t, hash.LoadSlice(b))
+			path = Dir(cp).FullPath(hash)
+		}
+		b, err := ioutil.ReadFile(path)
+		return string(b), err == nil
+	}
+}
@@ -0,0 +1,346 @@
+package stores
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+)
+
+const s3DefaultRegion = "us-east-1"
+
+// Objects named after their hash under Prefix, nested according to Part, in
+// Bucket of an S3-compatible service at Endpoint, addressed path-style.
+type S3 struct {
+	Endpoint string
+	Bucket   string
+	Prefix   string
+	Part     StrPart
+	Creds    S3Creds
+}
+
+// Anonymous requests without AccessKey.
+type S3Creds struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Region       string
+}
+
+var (
+	_ Store       = S3{}
+	_ Deleter     = S3{}
+	_ Quarantiner = S3{}
+)
+
+// Credentials are read from the standard AWS_* environment variables.
+func NewS3(endpoint, bucket, prefix string, part StrPart) S3 {
+	creds := S3Creds{
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		Region:       os.Getenv("AWS_REGION"),
+	}
+	if creds.Region == "" {
+		creds.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	return S3{
+		Endpoint: strings.TrimSuffix(endpoint, "/"),
+		Bucket:   bucket,
+		Prefix:   strings.Trim(prefix, "/"),
+		Part:     part,
+		Creds:    creds,
+	}
+}
+
+func (s S3) key(hash checksum.Hash) string {
+	filename := fmt.Sprintf("%x", hash)
+	parts := append(
+		append([]string{s.Prefix}, s.Part.Split(filename)...),
+		filename,
+	)
+	return path.Join(parts...)
+}
+
+// Same naming as Dir.QuarantinePath: not listed by Ls.
+func (s S3) quarantineKey(hash checksum.Hash) string {
+	return path.Join(s.Prefix, quarantineDir, fmt.Sprintf("corrupt-%x", hash))
+}
+
+func (s S3) Proc() procs.Proc {
+	return procs.InplaceFunc(s.process)
+}
+
+func (s S3) process(c *scat.Chunk) (err error) {
+	b, err := c.Data().Bytes()
+	if err != nil {
+		return
+	}
+	_, err = s.do("PUT", s.key(c.Hash()), nil, nil, b)
+	return
+}
+
+func (s S3) Unproc() procs.Proc {
+	return procs.ChunkFunc(s.unprocess)
+}
+
+func (s S3) unprocess(c *scat.Chunk) (new *scat.Chunk, err error) {
+	ctx, cancel := chunkContext(c)
+	defer cancel()
+	b, err := s.doCtx(ctx, "GET", s.key(c.Hash()), nil, nil, nil)
+	if err != nil && ctx.Err() != nil {
+		err = procs.ErrCanceled
+	}
+	new = c.WithData(scat.BytesData(b))
+	return
+}
+
+func (s S3) Delete(hash checksum.Hash) (err error) {
+	_, err = s.do("DELETE", s.key(hash), nil, nil, nil)
+	return
+}
+
+func (s S3) Quarantine(hash checksum.Hash) (err error) {
+	src := "/" + s.Bucket + "/" + s3Escape(s.key(hash), false)
+	hdr := map[string]string{"x-amz-copy-source": src}
+	_, err = s.do("PUT", s.quarantineKey(hash), nil, hdr, nil)
+	if err != nil {
+		return
+	}
+	return s.Delete(hash)
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key  string
+		Size int64
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (s S3) Ls() (entries []LsEntry, err error) {
+	prefix := s.Prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+	var (
+		query = url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		buf   = make([]byte, checksum.Size)
+		entry LsEntry
+	)
+	for {
+		var b []byte
+		b, err = s.do("GET", "", query, nil, nil)
+		if err != nil {
+			return
+		}
+		res := s3ListResult{}
+		err = xml.Unmarshal(b, &res)
+		if err != nil {
+			return
+		}
+		for _, obj := range res.Contents {
+			n, err := fmt.Sscanf(path.Base(obj.Key), "%x", &buf)
+			if err != nil || n != 1 {
+				continue
+			}
+			err = entry.Hash.LoadSlice(buf)
+			if err != nil {
+				continue
+			}
+			entry.Size = obj.Size
+			entries = append(entries, entry)
+		}
+		if !res.IsTruncated {
+			return
+		}
+		query.Set("continuation-token", res.NextContinuationToken)
+	}
+}
+
+type s3Error struct {
+	Status int
+	Code   string
+	Msg    string
+}
+
+func (e s3Error) Error() string {
+	return fmt.Sprintf("s3: %d %s: %s", e.Status, e.Code, e.Msg)
+}
+
+func (s S3) do(method, key string, query url.Values, hdr map[string]string,
+	body []byte,
+) ([]byte, error) {
+	return s.doCtx(context.Background(), method, key, query, hdr, body)
+}
+
+func (s S3) doCtx(ctx context.Context, method, key string, query url.Values,
+	hdr map[string]string, body []byte,
+) (out []byte, err error) {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return
+	}
+	u.Path = "/" + s.Bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = "/" + s.Bucket
+	if key != "" {
+		u.RawPath += "/" + s3Escape(key, false)
+	}
+	u.RawQuery = s3Query(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(),
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return
+	}
+	req.ContentLength = int64(len(body))
+	for k, v := range hdr {
+		req.Header.Set(k, v)
+	}
+	s.Creds.sign(req, body, time.Now())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	out, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode/100 == 2 {
+		return
+	}
+	e := s3Error{Status: resp.StatusCode}
+	xml.Unmarshal(out, &struct {
+		Code    *string
+		Message *string
+	}{&e.Code, &e.Msg})
+	out = nil
+	err = e
+	if e.Status == http.StatusNotFound {
+		err = procs.MissingDataError{err}
+	}
+	return
+}
+
+// AWS Signature Version 4
+func (cr S3Creds) sign(req *http.Request, body []byte, now time.Time) {
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if cr.AccessKey == "" {
+		return
+	}
+	region := cr.Region
+	if region == "" {
+		region = s3DefaultRegion
+	}
+	var (
+		amzDate = now.UTC().Format("20060102T150405Z")
+		date    = amzDate[:8]
+		scope   = date + "/" + region + "/s3/aws4_request"
+	)
+	req.Header.Set("x-amz-date", amzDate)
+	if cr.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", cr.SessionToken)
+	}
+	names := []string{"host"}
+	for k := range req.Header {
+		names = append(names, strings.ToLower(k))
+	}
+	sort.Strings(names)
+	canonHeaders := ""
+	for _, name := range names {
+		val := req.Header.Get(name)
+		if name == "host" {
+			val = req.URL.Host
+		}
+		canonHeaders += name + ":" + strings.TrimSpace(val) + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonReq := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	reqSum := sha256.Sum256([]byte(canonReq))
+	toSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(reqSum[:]),
+	}, "\n")
+	key := []byte("AWS4" + cr.SecretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSum(key, part)
+	}
+	sig := hex.EncodeToString(hmacSum(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cr.AccessKey, scope, signedHeaders, sig,
+	))
+}
+
+func hmacSum(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	io.WriteString(h, data)
+	return h.Sum(nil)
+}
+
+// Sorted by key, as required for signing.
+func s3Query(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			pairs = append(pairs, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func s3Escape(str string, escapeSlash bool) string {
+	buf := &bytes.Buffer{}
+	for _, b := range []byte(str) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~':
+			buf.WriteByte(b)
+		case b == '/' && !escapeSlash:
+			buf.WriteByte(b)
+		default:
+			fmt.Fprintf(buf, "%%%02X", b)
+		}
+	}
+	return buf.String()
+}
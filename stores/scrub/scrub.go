@@ -0,0 +1,231 @@
+package scrub
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/concur"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/stores"
+)
+
+// Verifies stored data against its hash, once undone by Unproc: the inverse of
+// the procs applied after the checksum proc, if any.
+//
+// As a wrong Unproc makes all objects look corrupt, quarantine is refused for a
+// store with more than MaxCorrupt percent of the objects checked found so.
+type Scrub struct {
+	Sample     float64 // percentage of objects to verify, 100 for all
+	Rate       int64   // max bytes read per second and store, 0 for no limit
+	Unproc     procs.Proc
+	Quarantine bool
+	MaxCorrupt float64
+}
+
+type errTooCorrupt struct {
+	ncorrupt, nchecked int
+}
+
+func (e errTooCorrupt) Error() string {
+	return fmt.Sprintf("%d of %d objects corrupt, refusing to quarantine:"+
+		" are stored chunks as checksummed, or undone by the unproc?",
+		e.ncorrupt, e.nchecked,
+	)
+}
+
+type Problem int
+
+const (
+	Corrupt Problem = iota
+	SizeMismatch
+	Unreadable
+)
+
+func (p Problem) String() string {
+	switch p {
+	case Corrupt:
+		return "corrupt"
+	case SizeMismatch:
+		return "size mismatch"
+	case Unreadable:
+		return "unreadable"
+	}
+	return fmt.Sprintf("Problem(%d)", int(p))
+}
+
+type Bad struct {
+	Hash        checksum.Hash
+	Problem     Problem
+	Err         error // why Unreadable
+	Quarantined bool
+}
+
+type Report struct {
+	Id          interface{}
+	Checked     int
+	CheckedSize int64
+	Skipped     int
+	Bad         []Bad
+}
+
+// Scrubs every object listed by each store, or only those among hashes when
+// not nil.
+func (sc Scrub) Run(ss []stores.NamedStore, hashes []checksum.Hash) (
+	reports []Report, err error,
+) {
+	if sc.Sample <= 0 || sc.Sample > 100 {
+		err = fmt.Errorf("invalid sample percentage %v", sc.Sample)
+		return
+	}
+	qrs := make([]stores.Quarantiner, len(ss))
+	for i, s := range ss {
+		qr, ok := s.Store.(stores.Quarantiner)
+		if !ok && sc.Quarantine {
+			err = fmt.Errorf("store %v: %v", s.Id(), stores.ErrNoQuarantine)
+			return
+		}
+		qrs[i] = qr
+	}
+	var only map[checksum.Hash]struct{}
+	if hashes != nil {
+		only = make(map[checksum.Hash]struct{}, len(hashes))
+		for _, h := range hashes {
+			only[h] = struct{}{}
+		}
+	}
+	reports = make([]Report, len(ss))
+	fns := make(concur.Funcs, len(ss))
+	for i := range ss {
+		s, qr, rep := ss[i], qrs[i], &reports[i]
+		rep.Id = s.Id()
+		fns[i] = func() (err error) {
+			ls, err := s.Ls()
+			if err != nil {
+				return
+			}
+			unproc := s.Unproc()
+			defer func() {
+				if ferr := unproc.Finish(); err == nil {
+					err = ferr
+				}
+			}()
+			lim := newLimiter(sc.Rate)
+			for _, e := range ls {
+				if only != nil {
+					if _, ok := only[e.Hash]; !ok {
+						continue
+					}
+				}
+				if rand.Float64()*100 >= sc.Sample {
+					rep.Skipped++
+					continue
+				}
+				bad, ok, n := sc.verify(unproc, e)
+				lim.wait(n)
+				rep.Checked++
+				rep.CheckedSize += n
+				if !ok {
+					rep.Bad = append(rep.Bad, bad)
+				}
+			}
+			if !sc.Quarantine {
+				return
+			}
+			err = sc.checkCorrupt(rep)
+			if err != nil {
+				return fmt.Errorf("store %v: %v", s.Id(), err)
+			}
+			for i, bad := range rep.Bad {
+				if bad.Problem == Unreadable {
+					continue
+				}
+				err = qr.Quarantine(bad.Hash)
+				if err != nil {
+					return
+				}
+				rep.Bad[i].Quarantined = true
+			}
+			return
+		}
+	}
+	err = fns.FirstErr()
+	return
+}
+
+func (sc Scrub) checkCorrupt(rep *Report) error {
+	ncorrupt := 0
+	for _, bad := range rep.Bad {
+		if bad.Problem == Corrupt {
+			ncorrupt++
+		}
+	}
+	pct := float64(ncorrupt) / float64(rep.Checked) * 100
+	if ncorrupt > 1 && pct > sc.MaxCorrupt {
+		return errTooCorrupt{ncorrupt, rep.Checked}
+	}
+	return nil
+}
+
+// Objects failing to download, whether missing or on a timeout or dropped
+// connection, are unreadable. Errors undoing procs applied after the checksum,
+// such as failed authentication of encrypted data, count as corruption.
+func (sc Scrub) verify(unproc procs.Proc, e stores.LsEntry) (
+	bad Bad, ok bool, n int64,
+) {
+	bad.Hash = e.Hash
+	c := scat.NewChunk(0, nil)
+	c.SetHash(e.Hash)
+	c, err := procs.ProcessResult(unproc, c)
+	if err != nil {
+		bad.Problem, bad.Err = Unreadable, err
+		return
+	}
+	b, err := c.Data().Bytes()
+	if err != nil {
+		bad.Problem, bad.Err = Unreadable, err
+		return
+	}
+	n = int64(len(b))
+	if n != e.Size {
+		bad.Problem = SizeMismatch
+		return
+	}
+	if sc.Unproc != nil {
+		c, err = procs.ProcessResult(sc.Unproc, c)
+		if err != nil {
+			bad.Problem = Corrupt
+			return
+		}
+	}
+	_, err = procs.ProcessResult(procs.ChecksumUnproc, c)
+	if err != nil {
+		bad.Problem = Corrupt
+		return
+	}
+	ok = true
+	return
+}
+
+type limiter struct {
+	rate  int64
+	start time.Time
+	total int64
+}
+
+func newLimiter(rate int64) *limiter {
+	return &limiter{rate: rate, start: time.Now()}
+}
+
+func (l *limiter) wait(n int64) {
+	if l.rate <= 0 {
+		return
+	}
+	l.total += n
+	due := time.Duration(float64(l.total) / float64(l.rate) * float64(time.Second))
+	if d := due - time.Since(l.start); d > 0 {
+		time.Sleep(d)
+	}
+}
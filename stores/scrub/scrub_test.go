@@ -0,0 +1,209 @@
+package scrub_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/stores"
+	"github.com/Roman2K/scat/stores/scrub"
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestScrub(t *testing.T) {
+	var (
+		hash1 = checksum.SumBytes([]byte("a"))
+		hash2 = checksum.SumBytes([]byte("b"))
+		hash3 = checksum.SumBytes([]byte("c"))
+	)
+
+	mem := stores.NewMem()
+	mem.Set(hash1, []byte("a"))
+	mem.Set(hash2, []byte("x"))
+	mem.Set(hash3, []byte("c"))
+	ss := []stores.NamedStore{
+		{"mem", mem},
+		{"badsize", badSize{mem}},
+	}
+
+	// report only
+	reports, err := scrub.Scrub{Sample: 100}.Run(ss, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(reports))
+	assert.Equal(t, scrub.Report{
+		Id:          "mem",
+		Checked:     3,
+		CheckedSize: 3,
+		Bad:         []scrub.Bad{{Hash: hash2, Problem: scrub.Corrupt}},
+	}, reports[0])
+	assert.Equal(t, 3, len(reports[1].Bad))
+	for _, bad := range reports[1].Bad {
+		assert.Equal(t, scrub.SizeMismatch, bad.Problem)
+	}
+	assert.Equal(t, 3, len(mem.Hashes()))
+
+	// only hashes from an index
+	reports, err = scrub.Scrub{Sample: 100}.Run(ss[:1], []checksum.Hash{hash1})
+	assert.NoError(t, err)
+	assert.Equal(t, []scrub.Report{{Id: "mem", Checked: 1, CheckedSize: 1}},
+		reports,
+	)
+
+	// quarantine
+	reports, err = scrub.Scrub{Sample: 100, Quarantine: true}.Run(ss[:1], nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []scrub.Bad{
+		{Hash: hash2, Problem: scrub.Corrupt, Quarantined: true},
+	}, reports[0].Bad)
+	assert.Equal(t, 2, len(mem.Hashes()))
+	assert.Equal(t, []checksum.Hash{hash2}, mem.Quarantined())
+
+	// quarantine unsupported
+	ss = []stores.NamedStore{{"ls", lsOnly{mem, mem}}}
+	_, err = scrub.Scrub{Sample: 100}.Run(ss, nil)
+	assert.NoError(t, err)
+	_, err = scrub.Scrub{Sample: 100, Quarantine: true}.Run(ss, nil)
+	assert.Error(t, err)
+}
+
+func TestScrubUnreadable(t *testing.T) {
+	hash := checksum.SumBytes([]byte("a"))
+	ss := []stores.NamedStore{
+		{"ls", lsOnly{stores.NewMem(), stores.SliceLister{{hash, 1}}}},
+	}
+	reports, err := scrub.Scrub{Sample: 100}.Run(ss, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(reports[0].Bad))
+	assert.Equal(t, scrub.Unreadable, reports[0].Bad[0].Problem)
+	assert.Equal(t, hash, reports[0].Bad[0].Hash)
+
+	// read errors other than missing data carried on past
+	mem := stores.NewMem()
+	mem.Set(hash, []byte("a"))
+	hash2 := checksum.SumBytes([]byte("b"))
+	mem.Set(hash2, []byte("b"))
+	someErr := errors.New("some err")
+	ss = []stores.NamedStore{{"flaky", flaky{mem, hash, someErr}}}
+	reports, err = scrub.Scrub{Sample: 100, Quarantine: true}.Run(ss, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, reports[0].Checked)
+	assert.Equal(t, []scrub.Bad{
+		{Hash: hash, Problem: scrub.Unreadable, Err: someErr},
+	}, reports[0].Bad)
+	assert.Equal(t, 2, len(mem.Hashes()))
+}
+
+func TestScrubUnproc(t *testing.T) {
+	mem := stores.NewMem()
+	gz := procs.Gzip{}
+	for _, s := range []string{"a", "b", "c"} {
+		c := scat.NewChunk(0, scat.BytesData(s))
+		c, err := procs.ProcessResult(gz.Proc(), c)
+		assert.NoError(t, err)
+		b, err := c.Data().Bytes()
+		assert.NoError(t, err)
+		mem.Set(checksum.SumBytes([]byte(s)), b)
+	}
+	ss := []stores.NamedStore{{"mem", mem}}
+
+	// all looking corrupt: quarantine refused
+	reports, err := scrub.Scrub{
+		Sample: 100, Quarantine: true, MaxCorrupt: 50,
+	}.Run(ss, nil)
+	assert.Error(t, err)
+	assert.Equal(t, 3, len(reports[0].Bad))
+	assert.False(t, reports[0].Bad[0].Quarantined)
+	assert.Equal(t, 3, len(mem.Hashes()))
+
+	// undone before verification
+	sc := scrub.Scrub{
+		Sample: 100, Unproc: gz.Unproc(), Quarantine: true, MaxCorrupt: 50,
+	}
+	reports, err = sc.Run(ss, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, reports[0].Checked)
+	assert.Equal(t, 0, len(reports[0].Bad))
+
+	// data not undoable counts as corrupt
+	mem.Set(checksum.SumBytes([]byte("a")), []byte("a"))
+	reports, err = sc.Run(ss, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(reports[0].Bad))
+	assert.Equal(t, scrub.Corrupt, reports[0].Bad[0].Problem)
+	assert.True(t, reports[0].Bad[0].Quarantined)
+}
+
+func TestScrubSample(t *testing.T) {
+	mem := stores.NewMem()
+	for _, s := range []string{"a", "b", "c", "d"} {
+		mem.Set(checksum.SumBytes([]byte(s)), []byte(s))
+	}
+	ss := []stores.NamedStore{{"mem", mem}}
+
+	reports, err := scrub.Scrub{Sample: 100}.Run(ss, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, reports[0].Checked)
+	assert.Equal(t, 0, reports[0].Skipped)
+
+	reports, err = scrub.Scrub{Sample: 50}.Run(ss, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, reports[0].Checked+reports[0].Skipped)
+
+	_, err = scrub.Scrub{Sample: 101}.Run(ss, nil)
+	assert.Error(t, err)
+	_, err = scrub.Scrub{Sample: 0}.Run(ss, nil)
+	assert.Error(t, err)
+}
+
+func TestScrubRate(t *testing.T) {
+	const rate = 100
+	mem := stores.NewMem()
+	data := make([]byte, rate/10)
+	mem.Set(checksum.SumBytes(data), data)
+	ss := []stores.NamedStore{{"mem", mem}}
+
+	start := time.Now()
+	_, err := scrub.Scrub{Sample: 100, Rate: rate}.Run(ss, nil)
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+}
+
+type badSize struct {
+	*stores.Mem
+}
+
+func (s badSize) Ls() (entries []stores.LsEntry, err error) {
+	entries, err = s.Mem.Ls()
+	for i := range entries {
+		entries[i].Size++
+	}
+	return
+}
+
+type flaky struct {
+	*stores.Mem
+	hash checksum.Hash
+	err  error
+}
+
+func (s flaky) Unproc() procs.Proc {
+	unproc := s.Mem.Unproc()
+	return procs.ChunkFunc(func(c *scat.Chunk) (*scat.Chunk, error) {
+		if c.Hash() == s.hash {
+			return nil, s.err
+		}
+		return procs.ProcessResult(unproc, c)
+	})
+}
+
+type lsOnly struct {
+	stores.Store
+	stores.Lister
+}
+
+func (s lsOnly) Ls() ([]stores.LsEntry, error) {
+	return s.Lister.Ls()
+}
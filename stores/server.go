@@ -0,0 +1,239 @@
+package stores
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+)
+
+// Serves Cp to Remote stores, and to Http ones as a plain HTTP server:
+//
+//	GET    /                    JSON listing of [{"name", "size"}, ...]
+//	PUT    /<hash>              atomic write
+//	GET    /<hash>              read
+//	DELETE /<hash>              delete
+//	MOVE   /<hash>              quarantine, Destination being
+//	                            /quarantine/corrupt-<hash>
+//	PUT    /quarantine/corrupt-<hash>
+//
+// Bodies of PUT requests are verified against their "Digest: sha-256=..."
+// header when present, and responses to GET include one.
+type Server struct {
+	Cp   Cp
+	User string
+	Pass string
+}
+
+var _ http.Handler = Server{}
+
+const quarantinePrefix = "/" + quarantineDir + "/corrupt-"
+
+func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.User != "" {
+		user, pass, ok := r.BasicAuth()
+		okUser := subtle.ConstantTimeCompare([]byte(user), []byte(s.User))
+		okPass := subtle.ConstantTimeCompare([]byte(pass), []byte(s.Pass))
+		if !ok || okUser&okPass != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="scat"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	err := s.serve(w, r)
+	if err == nil {
+		return
+	}
+	status := http.StatusInternalServerError
+	switch err.(type) {
+	case procs.MissingDataError:
+		status = http.StatusNotFound
+	case serverError:
+		status = err.(serverError).status
+	}
+	http.Error(w, err.Error(), status)
+}
+
+type serverError struct {
+	status int
+	msg    string
+}
+
+func (e serverError) Error() string {
+	return e.msg
+}
+
+var (
+	errServerBadRequest = serverError{http.StatusBadRequest, "bad request"}
+	errServerMethod     = serverError{
+		http.StatusMethodNotAllowed, "method not allowed",
+	}
+	errDigestInvalid  = serverError{http.StatusBadRequest, "invalid digest"}
+	errDigestMismatch = serverError{http.StatusBadRequest, "digest mismatch"}
+)
+
+func (s Server) serve(w http.ResponseWriter, r *http.Request) error {
+	p := r.URL.Path
+	if p == "/" {
+		if r.Method != "GET" {
+			return errServerMethod
+		}
+		return s.ls(w)
+	}
+	if strings.HasPrefix(p, quarantinePrefix) {
+		hash, err := serverHash(strings.TrimPrefix(p, quarantinePrefix))
+		if err != nil {
+			return err
+		}
+		if r.Method != "PUT" {
+			return errServerMethod
+		}
+		return s.put(w, r, Dir(s.Cp).QuarantinePath(hash))
+	}
+	hash, err := serverHash(strings.TrimPrefix(p, "/"))
+	if err != nil {
+		return err
+	}
+	switch r.Method {
+	case "GET":
+		return s.get(w, hash)
+	case "PUT":
+		return s.put(w, r, Dir(s.Cp).FullPath(hash))
+	case "DELETE":
+		err = s.Cp.Delete(hash)
+	case "MOVE":
+		dst, perr := url.Parse(r.Header.Get("Destination"))
+		// Suffix in case of a reverse proxy serving under a sub-path
+		want := fmt.Sprintf("%s%x", quarantinePrefix, hash)
+		if perr != nil || !strings.HasSuffix(dst.Path, want) {
+			return errServerBadRequest
+		}
+		err = s.Cp.Quarantine(hash)
+	default:
+		return errServerMethod
+	}
+	if err == nil {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return err
+}
+
+func serverHash(str string) (hash checksum.Hash, err error) {
+	b, err := hex.DecodeString(str)
+	if err == nil {
+		err = hash.LoadSlice(b)
+	}
+	if err != nil {
+		err = errServerBadRequest
+	}
+	return
+}
+
+func (s Server) ls(w http.ResponseWriter) error {
+	ls, err := s.Cp.Ls()
+	if err != nil {
+		return err
+	}
+	files := make([]httpFile, len(ls))
+	for i, e := range ls {
+		files[i] = httpFile{fmt.Sprintf("%x", e.Hash), e.Size}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(files)
+}
+
+func (s Server) get(w http.ResponseWriter, hash checksum.Hash) error {
+	b, err := ioutil.ReadFile(Dir(s.Cp).FullPath(hash))
+	if os.IsNotExist(err) {
+		err = procs.MissingDataError{err}
+	}
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(b)
+	w.Header().Set("Digest", digestHeader(sum[:]))
+	_, err = w.Write(b)
+	return err
+}
+
+// Written to a temporary file, renamed once complete and verified. Its name
+// starts with a dot so as not to be listed meanwhile.
+func (s Server) put(w http.ResponseWriter, r *http.Request, path string,
+) (err error) {
+	want, err := parseDigest(r.Header.Get("Digest"))
+	if err != nil {
+		return
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return
+	}
+	rnd := make([]byte, 8)
+	_, err = rand.Read(rnd)
+	if err != nil {
+		return
+	}
+	tmp := filepath.Join(filepath.Dir(path),
+		fmt.Sprintf(".tmp-%s-%x", filepath.Base(path), rnd),
+	)
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), r.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+	if want != nil && string(h.Sum(nil)) != string(want) {
+		return errDigestMismatch
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	return
+}
+
+func digestHeader(sum []byte) string {
+	return "sha-256=" + base64.StdEncoding.EncodeToString(sum)
+}
+
+// Nil sum without a SHA-256 digest.
+func parseDigest(hdr string) (sum []byte, err error) {
+	for _, d := range strings.Split(hdr, ",") {
+		d = strings.TrimSpace(d)
+		idx := strings.IndexByte(d, '=')
+		if idx == -1 || strings.ToLower(d[:idx]) != "sha-256" {
+			continue
+		}
+		sum, err = base64.StdEncoding.DecodeString(d[idx+1:])
+		if err != nil || len(sum) != sha256.Size {
+			sum, err = nil, errDigestInvalid
+		}
+		return
+	}
+	return
+}
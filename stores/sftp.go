@@ -0,0 +1,452 @@
+package stores
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const sftpDefaultSessions = 4
+
+// Files nested like Dir on a host reached over a single persistent SSH
+// connection, on which Sessions SFTP sessions are multiplexed. Connects on
+// first use, and again on next use after the connection drops. Disconnects
+// once all procs are finished.
+type Sftp struct {
+	Dir      Dir
+	Addr     string
+	Config   *ssh.ClientConfig
+	Sessions int
+
+	mu     sync.Mutex
+	conn   *sftpConn
+	nprocs int
+}
+
+type sftpConn struct {
+	ssh      *ssh.Client
+	sessions []*sftp.Client
+	next     int
+}
+
+var (
+	_ Store       = &Sftp{}
+	_ Deleter     = &Sftp{}
+	_ Quarantiner = &Sftp{}
+)
+
+// Resolves host through ~/.ssh/config like ssh would, authenticates with
+// agent keys then identity files, and verifies the host key against
+// known_hosts.
+func NewSftp(userHost string, dir Dir) (s *Sftp, err error) {
+	user, host := "", userHost
+	if idx := strings.LastIndex(userHost, "@"); idx != -1 {
+		user, host = userHost[:idx], userHost[idx+1:]
+	}
+	hc, err := readSshConfig(expandHome("~/.ssh/config"), host)
+	if err != nil {
+		return
+	}
+	if hc.HostName != "" {
+		host = hc.HostName
+	}
+	port := hc.Port
+	if port == "" {
+		port = "22"
+	}
+	if user == "" {
+		user = hc.User
+	}
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+	addr := net.JoinHostPort(host, port)
+	hostKey, hostKeyAlgos, err := sshHostKeyCallback(hc.KnownHosts, addr)
+	if err != nil {
+		return
+	}
+	s = &Sftp{
+		Dir:  dir,
+		Addr: addr,
+		Config: &ssh.ClientConfig{
+			User:              user,
+			Auth:              []ssh.AuthMethod{sshAuth(hc)},
+			HostKeyCallback:   hostKey,
+			HostKeyAlgorithms: hostKeyAlgos,
+			Timeout:           30 * time.Second,
+		},
+		Sessions: sftpDefaultSessions,
+	}
+	return
+}
+
+func sshAuth(hc sshHostConfig) ssh.AuthMethod {
+	var ag agent.ExtendedAgent
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" && !hc.IdentitiesOnly {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			ag = agent.NewClient(conn)
+		}
+	}
+	files, explicit := hc.IdentityFiles, true
+	if len(files) == 0 {
+		files, explicit = nil, false
+		for _, name := range []string{"id_rsa", "id_ecdsa", "id_ed25519"} {
+			files = append(files, expandHome("~/.ssh/"+name))
+		}
+	}
+	return ssh.PublicKeysCallback(func() (signers []ssh.Signer, err error) {
+		if ag != nil {
+			signers, err = ag.Signers()
+			if err != nil {
+				return
+			}
+		}
+		for _, f := range files {
+			b, err := ioutil.ReadFile(f)
+			if os.IsNotExist(err) && !explicit {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			signer, err := ssh.ParsePrivateKey(b)
+			if _, ok := err.(*ssh.PassphraseMissingError); ok {
+				// Most likely loaded in the agent
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			signers = append(signers, signer)
+		}
+		return
+	})
+}
+
+// Also returns the algorithms of the keys known for addr, for the server not
+// to present a key of another type, which would be rejected as a mismatch.
+func sshHostKeyCallback(files []string, addr string) (
+	cb ssh.HostKeyCallback, algos []string, err error,
+) {
+	if len(files) == 0 {
+		files = []string{
+			expandHome("~/.ssh/known_hosts"),
+			expandHome("~/.ssh/known_hosts2"),
+		}
+	}
+	existing := []string{}
+	for _, f := range files {
+		if _, err := os.Stat(f); err == nil {
+			existing = append(existing, f)
+		}
+	}
+	cb, err = knownhosts.New(existing...)
+	if err != nil {
+		return
+	}
+	err = cb(addr, &net.TCPAddr{}, sshProbeKey{})
+	keyErr, ok := err.(*knownhosts.KeyError)
+	err = nil
+	if !ok {
+		return
+	}
+	for _, k := range keyErr.Want {
+		switch t := k.Key.Type(); t {
+		case ssh.KeyAlgoRSA:
+			algos = append(algos,
+				ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA,
+			)
+		default:
+			algos = append(algos, t)
+		}
+	}
+	return
+}
+
+// Matches no known key: only for looking up those known for a host.
+type sshProbeKey struct{}
+
+func (sshProbeKey) Type() string    { return "probe" }
+func (sshProbeKey) Marshal() []byte { return []byte("probe") }
+func (sshProbeKey) Verify([]byte, *ssh.Signature) error {
+	return errors.New("probe key")
+}
+
+func (s *Sftp) session() (cl *sftp.Client, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		s.conn, err = s.dial()
+		if err != nil {
+			return
+		}
+	}
+	conn := s.conn
+	conn.next = (conn.next + 1) % len(conn.sessions)
+	cl = conn.sessions[conn.next]
+	return
+}
+
+func (s *Sftp) dial() (conn *sftpConn, err error) {
+	sc, err := ssh.Dial("tcp", s.Addr, s.Config)
+	if err != nil {
+		return
+	}
+	conn = &sftpConn{ssh: sc}
+	n := s.Sessions
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		var cl *sftp.Client
+		cl, err = sftp.NewClient(sc)
+		if err != nil {
+			sc.Close()
+			return nil, err
+		}
+		conn.sessions = append(conn.sessions, cl)
+	}
+	go func() {
+		sc.Wait()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.conn == conn {
+			s.conn = nil
+		}
+	}()
+	return
+}
+
+func (s *Sftp) Close() error {
+	s.mu.Lock()
+	conn := s.conn
+	s.conn = nil
+	s.mu.Unlock()
+	return conn.close()
+}
+
+func (conn *sftpConn) close() error {
+	if conn == nil {
+		return nil
+	}
+	for _, cl := range conn.sessions {
+		cl.Close()
+	}
+	return conn.ssh.Close()
+}
+
+func (s *Sftp) open(p procs.Proc) procs.Proc {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nprocs++
+	return &sftpProc{Proc: p, s: s}
+}
+
+func (s *Sftp) release() error {
+	s.mu.Lock()
+	s.nprocs--
+	var conn *sftpConn
+	if s.nprocs == 0 {
+		conn, s.conn = s.conn, nil
+	}
+	s.mu.Unlock()
+	return conn.close()
+}
+
+type sftpProc struct {
+	procs.Proc
+	s    *Sftp
+	once sync.Once
+}
+
+func (p *sftpProc) Finish() (err error) {
+	err = p.Proc.Finish()
+	p.once.Do(func() {
+		if cerr := p.s.release(); err == nil {
+			err = cerr
+		}
+	})
+	return
+}
+
+func (s *Sftp) Proc() procs.Proc {
+	return s.open(procs.InplaceFunc(s.process))
+}
+
+// Writes to a temporary file starting with a dot so as not to be listed, then
+// renamed into place.
+func (s *Sftp) process(c *scat.Chunk) (err error) {
+	cl, err := s.session()
+	if err != nil {
+		return
+	}
+	name := filepath.ToSlash(s.Dir.FullPath(c.Hash()))
+	rnd := make([]byte, 8)
+	_, err = rand.Read(rnd)
+	if err != nil {
+		return
+	}
+	tmp := path.Join(path.Dir(name),
+		fmt.Sprintf(".tmp-%s-%x", path.Base(name), rnd),
+	)
+	open := func() (*sftp.File, error) {
+		return cl.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	}
+	f, err := open()
+	if os.IsNotExist(err) {
+		err = cl.MkdirAll(path.Dir(name))
+		if err != nil {
+			return
+		}
+		f, err = open()
+	}
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			cl.Remove(tmp)
+		}
+	}()
+	_, err = io.Copy(f, c.Data().Reader())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+	err = cl.PosixRename(tmp, name)
+	if err != nil {
+		// Server without the posix-rename extension
+		err = cl.Rename(tmp, name)
+	}
+	return
+}
+
+func (s *Sftp) Unproc() procs.Proc {
+	return s.open(procs.ChunkFunc(s.unprocess))
+}
+
+func (s *Sftp) unprocess(c *scat.Chunk) (new *scat.Chunk, err error) {
+	cl, err := s.session()
+	if err != nil {
+		return
+	}
+	f, err := cl.Open(filepath.ToSlash(s.Dir.FullPath(c.Hash())))
+	if os.IsNotExist(err) {
+		err = procs.MissingDataError{err}
+	}
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if done := procs.Done(c); done != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-done:
+				f.Close()
+			case <-stop:
+			}
+		}()
+	}
+	buf := &bytes.Buffer{}
+	_, err = io.Copy(buf, f)
+	if err != nil && procs.Canceled(c) {
+		err = procs.ErrCanceled
+	}
+	new = c.WithData(scat.BytesData(buf.Bytes()))
+	return
+}
+
+func (s *Sftp) Delete(hash checksum.Hash) (err error) {
+	cl, err := s.session()
+	if err != nil {
+		return
+	}
+	err = cl.Remove(filepath.ToSlash(s.Dir.FullPath(hash)))
+	if os.IsNotExist(err) {
+		err = procs.MissingDataError{err}
+	}
+	return
+}
+
+func (s *Sftp) Quarantine(hash checksum.Hash) (err error) {
+	cl, err := s.session()
+	if err != nil {
+		return
+	}
+	name := filepath.ToSlash(s.Dir.QuarantinePath(hash))
+	err = cl.MkdirAll(path.Dir(name))
+	if err != nil {
+		return
+	}
+	err = cl.Rename(filepath.ToSlash(s.Dir.FullPath(hash)), name)
+	if os.IsNotExist(err) {
+		err = procs.MissingDataError{err}
+	}
+	return
+}
+
+func (s *Sftp) Ls() ([]LsEntry, error) {
+	return s.Dir.Ls(sftpLister{s})
+}
+
+type sftpLister struct {
+	s *Sftp
+}
+
+func (l sftpLister) Ls(dir string, depth int) <-chan DirLsRes {
+	ch := make(chan DirLsRes)
+	go func() {
+		defer close(ch)
+		cl, err := l.s.session()
+		if err == nil {
+			err = sftpWalk(cl, filepath.ToSlash(dir), depth, ch)
+		}
+		if err != nil {
+			ch <- DirLsRes{Err: err}
+		}
+	}()
+	return ch
+}
+
+// Sends files found depth levels below dir, like localLister.
+func sftpWalk(cl *sftp.Client, dir string, depth int, ch chan<- DirLsRes,
+) error {
+	fis, err := cl.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		switch {
+		case depth > 1 && fi.IsDir():
+			err = sftpWalk(cl, path.Join(dir, fi.Name()), depth-1, ch)
+			if err != nil {
+				return err
+			}
+		case depth == 1 && !fi.IsDir():
+			ch <- DirLsRes{Name: fi.Name(), Size: fi.Size()}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package stores
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Roman2K/scat"
+	"github.com/Roman2K/scat/checksum"
+	"github.com/Roman2K/scat/procs"
+	"github.com/Roman2K/scat/testutil"
+	"github.com/pkg/sftp"
+	assert "github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestParseSshConfig(t *testing.T) {
+	const config = `
+# comment
+Host foo bar?
+  HostName foo.example.com
+  User alice
+  IdentityFile ~/.ssh/foo
+
+Host barbaz !bar
+  Port 2222
+
+Match host bar2
+  User bob
+
+Host *
+  User=default
+  Port 22
+  IdentityFile "%d/.ssh/id"
+`
+	home := os.Getenv("HOME")
+	parse := func(host string) sshHostConfig {
+		hc, err := parseSshConfig(strings.NewReader(config), host)
+		assert.NoError(t, err)
+		return hc
+	}
+
+	assert.Equal(t, sshHostConfig{
+		HostName: "foo.example.com",
+		User:     "alice",
+		Port:     "22",
+		IdentityFiles: []string{
+			filepath.Join(home, ".ssh/foo"),
+			filepath.Join(home, ".ssh/id"),
+		},
+	}, parse("foo"))
+
+	assert.Equal(t, sshHostConfig{
+		User:          "default",
+		Port:          "2222",
+		IdentityFiles: []string{filepath.Join(home, ".ssh/id")},
+	}, parse("barbaz"))
+
+	assert.Equal(t, "alice", parse("bar2").User)
+	assert.Equal(t, "", parse("baz").HostName)
+}
+
+func TestSftp(t *testing.T) {
+	root, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	home := filepath.Join(root, "home")
+	remote := filepath.Join(root, "remote")
+	assert.NoError(t, os.MkdirAll(filepath.Join(home, ".ssh"), 0700))
+	t.Setenv("HOME", home)
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	// client key, server
+	_, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(
+		filepath.Join(home, ".ssh", "scat_key"), pem.EncodeToMemory(block), 0600,
+	)
+	assert.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(clientKey)
+	assert.NoError(t, err)
+	srv := newSftpServer(t, signer.PublicKey())
+	defer srv.close()
+
+	// ssh config, known_hosts
+	host, port, err := net.SplitHostPort(srv.addr)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(home, ".ssh", "config"), []byte(""+
+		"Host myhost\n"+
+		"  HostName "+host+"\n"+
+		"  Port "+port+"\n"+
+		"  User scat\n"+
+		"  IdentityFile ~/.ssh/scat_key\n",
+	), 0600)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), []byte(
+		knownhosts.Line([]string{knownhosts.Normalize(srv.addr)}, srv.hostKey)+
+			"\n",
+	), 0600)
+	assert.NoError(t, err)
+
+	s, err := NewSftp("myhost", Dir{remote, StrPart{2}})
+	assert.NoError(t, err)
+	defer s.Close()
+	assert.Equal(t, srv.addr, s.Addr)
+	assert.Equal(t, "scat", s.Config.User)
+	assert.Equal(t, []string{ssh.KeyAlgoED25519}, s.Config.HostKeyAlgorithms)
+
+	var (
+		hash1 = testutil.Hash1.Hash
+		hash2 = testutil.Hashes[1].Hash
+		path1 = filepath.Join(remote, testutil.Hash1.Hex[:2], testutil.Hash1.Hex)
+	)
+	put := func(hash checksum.Hash, data string) {
+		c := scat.NewChunk(0, scat.BytesData(data))
+		c.SetHash(hash)
+		_, err := testutil.ReadChunks(s.Proc().Process(c))
+		assert.NoError(t, err)
+	}
+	get := func(hash checksum.Hash) (string, error) {
+		c := scat.NewChunk(0, nil)
+		c.SetHash(hash)
+		chunks, err := testutil.ReadChunks(s.Unproc().Process(c))
+		if err != nil {
+			return "", err
+		}
+		b, err := chunks[0].Data().Bytes()
+		return string(b), err
+	}
+	ls := func() []LsEntry {
+		ls, err := s.Ls()
+		assert.NoError(t, err)
+		sort.Slice(ls, func(i, j int) bool {
+			return ls[i].Size > ls[j].Size
+		})
+		return ls
+	}
+
+	// write concurrently, read
+	wg := sync.WaitGroup{}
+	for _, data := range []string{"abc", "abc", "abc"} {
+		wg.Add(1)
+		go func(data string) {
+			defer wg.Done()
+			put(hash1, data)
+		}(data)
+	}
+	wg.Wait()
+	put(hash2, "de")
+	b, err := ioutil.ReadFile(path1)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", string(b))
+	data, err := get(hash1)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", data)
+	assert.Equal(t, 1, srv.connCount())
+	fis, err := ioutil.ReadDir(filepath.Dir(path1))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(fis))
+
+	// ls
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(remote, "foo"), nil, 0644))
+	assert.Equal(t, []LsEntry{{hash1, 3}, {hash2, 2}}, ls())
+
+	// quarantine
+	err = s.Quarantine(hash1)
+	assert.NoError(t, err)
+	_, err = os.Stat(path1)
+	assert.True(t, os.IsNotExist(err))
+	b, err = ioutil.ReadFile(filepath.Join(remote, "quarantine",
+		"corrupt-"+testutil.Hash1.Hex,
+	))
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", string(b))
+	assert.Equal(t, []LsEntry{{hash2, 2}}, ls())
+
+	// missing
+	_, err = get(hash1)
+	_, ok := err.(procs.MissingDataError)
+	assert.True(t, ok)
+	err = s.Quarantine(hash1)
+	_, ok = err.(procs.MissingDataError)
+	assert.True(t, ok)
+	err = s.Delete(hash1)
+	_, ok = err.(procs.MissingDataError)
+	assert.True(t, ok)
+
+	// reconnect
+	srv.dropConns()
+	for i := 0; ; i++ {
+		_, err = s.Ls()
+		if err == nil {
+			break
+		}
+		assert.True(t, i < 100, "no reconnection")
+	}
+	assert.Equal(t, 2, srv.connCount())
+
+	// delete
+	err = s.Delete(hash2)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(ls()))
+
+	// disconnected once all procs finished
+	s2, err := NewSftp("myhost", Dir{remote, StrPart{2}})
+	assert.NoError(t, err)
+	proc, unproc := s2.Proc(), s2.Unproc()
+	c := scat.NewChunk(0, scat.BytesData("abc"))
+	c.SetHash(hash1)
+	_, err = testutil.ReadChunks(proc.Process(c))
+	assert.NoError(t, err)
+	assert.NoError(t, proc.Finish())
+	assert.NoError(t, proc.Finish())
+	assert.NotNil(t, s2.conn)
+	assert.NoError(t, unproc.Finish())
+	assert.Nil(t, s2.conn)
+}
+
+type sftpServer struct {
+	addr    string
+	hostKey ssh.PublicKey
+	ln      net.Listener
+	conns   []net.Conn
+	mu      sync.Mutex
+}
+
+func newSftpServer(t *testing.T, clientKey ssh.PublicKey) *sftpServer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(key)
+	assert.NoError(t, err)
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (
+			*ssh.Permissions, error,
+		) {
+			if meta.User() == "scat" &&
+				string(key.Marshal()) == string(clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	config.AddHostKey(hostSigner)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	srv := &sftpServer{
+		addr:    ln.Addr().String(),
+		hostKey: hostSigner.PublicKey(),
+		ln:      ln,
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			srv.mu.Lock()
+			srv.conns = append(srv.conns, conn)
+			srv.mu.Unlock()
+			go srv.serve(conn, config)
+		}
+	}()
+	return srv
+}
+
+func (srv *sftpServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "")
+			continue
+		}
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range reqs {
+				ok := req.Type == "subsystem" &&
+					string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+				go func() {
+					defer ch.Close()
+					server, err := sftp.NewServer(ch)
+					if err != nil {
+						return
+					}
+					server.Serve()
+				}()
+			}
+		}()
+	}
+}
+
+func (srv *sftpServer) connCount() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return len(srv.conns)
+}
+
+func (srv *sftpServer) dropConns() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for _, conn := range srv.conns {
+		conn.Close()
+	}
+}
+
+func (srv *sftpServer) close() {
+	srv.ln.Close()
+	srv.dropConns()
+}
@@ -0,0 +1,117 @@
+package stores
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Subset of ssh_config(5) relevant to connecting: the first value obtained
+// for each keyword wins, except for IdentityFile which accumulates.
+type sshHostConfig struct {
+	HostName       string
+	User           string
+	Port           string
+	IdentityFiles  []string
+	KnownHosts     []string
+	IdentitiesOnly bool
+}
+
+func readSshConfig(file, host string) (hc sshHostConfig, err error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return parseSshConfig(f, host)
+}
+
+func parseSshConfig(r io.Reader, host string) (hc sshHostConfig, err error) {
+	var (
+		scan  = bufio.NewScanner(r)
+		match = true
+		seen  = map[string]bool{}
+	)
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, val := line, ""
+		if idx := strings.IndexAny(line, " \t="); idx != -1 {
+			key = line[:idx]
+			val = strings.TrimLeft(line[idx:], " \t=")
+		}
+		key = strings.ToLower(key)
+		val = strings.Trim(val, `"`)
+		switch key {
+		case "host":
+			match = sshHostMatch(strings.Fields(val), host)
+			continue
+		case "match":
+			// Criteria unsupported: never match
+			match = false
+			continue
+		}
+		if !match {
+			continue
+		}
+		if key == "identityfile" {
+			hc.IdentityFiles = append(hc.IdentityFiles, expandHome(val))
+			continue
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		switch key {
+		case "hostname":
+			hc.HostName = val
+		case "user":
+			hc.User = val
+		case "port":
+			hc.Port = val
+		case "userknownhostsfile":
+			for _, f := range strings.Fields(val) {
+				hc.KnownHosts = append(hc.KnownHosts, expandHome(f))
+			}
+		case "identitiesonly":
+			hc.IdentitiesOnly = strings.ToLower(val) == "yes"
+		}
+	}
+	err = scan.Err()
+	return
+}
+
+func sshHostMatch(patterns []string, host string) (ok bool) {
+	for _, p := range patterns {
+		neg := strings.HasPrefix(p, "!")
+		if neg {
+			p = p[1:]
+		}
+		if m, _ := path.Match(p, host); !m {
+			continue
+		}
+		if neg {
+			return false
+		}
+		ok = true
+	}
+	return
+}
+
+func expandHome(p string) string {
+	home := os.Getenv("HOME")
+	p = strings.Replace(p, "%d", home, -1)
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		p = filepath.Join(home, p[1:])
+	}
+	return p
+}
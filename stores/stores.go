@@ -1,7 +1,9 @@
 package stores
 
 import (
+	"errors"
 	"math/rand"
+	"time"
 
 	"github.com/Roman2K/scat/checksum"
 	"github.com/Roman2K/scat/concur"
@@ -19,6 +21,38 @@ type Lister interface {
 	Ls() ([]LsEntry, error)
 }
 
+type Deleter interface {
+	Delete(checksum.Hash) error
+}
+
+type Quarantiner interface {
+	Quarantine(checksum.Hash) error
+}
+
+var (
+	ErrNoDelete     = errors.New("store doesn't support deletion")
+	ErrNoQuarantine = errors.New("store doesn't support quarantine")
+	ErrNoBatch      = errors.New("store doesn't support batching")
+)
+
+// Stores able to upload chunks in batches of up to size, each in a single
+// command.
+type Batcher interface {
+	WithBatch(size int) (Store, error)
+}
+
+// Max time waited for more chunks before uploading a batch
+var batchDelay = 200 * time.Millisecond // var for tests
+
+type NamedStore struct {
+	IdVal interface{}
+	Store
+}
+
+func (s NamedStore) Id() interface{} {
+	return s.IdVal
+}
+
 type LsEntry struct {
 	Hash checksum.Hash
 	Size int64
@@ -42,26 +76,61 @@ func (cp Copier) Id() interface{} {
 	return cp.IdVal
 }
 
+func (cp Copier) LsCached(fn func(added, removed []LsEntry)) (
+	[]LsEntry, error,
+) {
+	if cl, ok := cp.Lister.(CachedLister); ok {
+		return cl.LsCached(fn)
+	}
+	return cp.Ls()
+}
+
 type LsEntryAdder interface {
 	AddLsEntry(Lister, LsEntry)
 }
 
+// Adders also told of entries found gone by background refreshes
+type LsEntryRemover interface {
+	RemoveLsEntry(Lister, LsEntry)
+}
+
 type MultiLister []Lister
 
+// Cached listings are added first, then corrected once refreshed.
 func (ml MultiLister) AddEntriesTo(adders []LsEntryAdder) error {
 	fns := make(concur.Funcs, len(ml))
 	for i := range ml {
 		lser := ml[i]
-		fns[i] = func() (err error) {
-			ls, err := lser.Ls()
-			if err != nil {
-				return
-			}
+		apply := func(added, removed []LsEntry) {
 			for _, a := range adders {
-				for _, e := range ls {
+				for _, e := range added {
 					a.AddLsEntry(lser, e)
 				}
+				r, ok := a.(LsEntryRemover)
+				if !ok {
+					continue
+				}
+				for _, e := range removed {
+					r.RemoveLsEntry(lser, e)
+				}
+			}
+		}
+		fns[i] = func() (err error) {
+			var ls []LsEntry
+			if cl, ok := lser.(CachedLister); ok {
+				applied := make(chan struct{})
+				defer close(applied)
+				ls, err = cl.LsCached(func(added, removed []LsEntry) {
+					<-applied
+					apply(added, removed)
+				})
+			} else {
+				ls, err = lser.Ls()
 			}
+			if err != nil {
+				return
+			}
+			apply(ls, nil)
 			return
 		}
 	}
@@ -77,6 +146,11 @@ func (a CopiesEntryAdder) AddLsEntry(lser Lister, e LsEntry) {
 	a.Reg.List(e.Hash).Add(owner)
 }
 
+func (a CopiesEntryAdder) RemoveLsEntry(lser Lister, e LsEntry) {
+	owner := lser.(copies.Owner)
+	a.Reg.List(e.Hash).Remove(owner)
+}
+
 type QuotaEntryAdder struct {
 	Qman *quota.Man
 }
@@ -85,6 +159,10 @@ func (a QuotaEntryAdder) AddLsEntry(lser Lister, e LsEntry) {
 	a.Qman.AddUse(lser.(quota.Res), uint64(e.Size))
 }
 
+func (a QuotaEntryAdder) RemoveLsEntry(lser Lister, e LsEntry) {
+	a.Qman.RemoveUse(lser.(quota.Res), uint64(e.Size))
+}
+
 func ShuffleCopiers(copiers []Copier) (res []Copier) {
 	indexes := rand.Perm(len(copiers))
 	res = make([]Copier, len(indexes))